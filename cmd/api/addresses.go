@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) listAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	addresses, err := app.models.Addresses.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"addresses": addresses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Label      string `json:"label"`
+		Line1      string `json:"line1"`
+		Line2      string `json:"line2"`
+		City       string `json:"city"`
+		PostalCode string `json:"postal_code"`
+		Phone      string `json:"phone"`
+		Notes      string `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	address := &data.Address{
+		UserID:     user.ID,
+		Label:      input.Label,
+		Line1:      input.Line1,
+		Line2:      input.Line2,
+		City:       input.City,
+		PostalCode: input.PostalCode,
+		Phone:      input.Phone,
+		Notes:      input.Notes,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAddress(v, address); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Addresses.Insert(address)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/users/me/addresses/%d", address.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"address": address}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateAddressHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	address, err := app.models.Addresses.GetForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Label      *string `json:"label"`
+		Line1      *string `json:"line1"`
+		Line2      *string `json:"line2"`
+		City       *string `json:"city"`
+		PostalCode *string `json:"postal_code"`
+		Phone      *string `json:"phone"`
+		Notes      *string `json:"notes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Label != nil {
+		address.Label = *input.Label
+	}
+	if input.Line1 != nil {
+		address.Line1 = *input.Line1
+	}
+	if input.Line2 != nil {
+		address.Line2 = *input.Line2
+	}
+	if input.City != nil {
+		address.City = *input.City
+	}
+	if input.PostalCode != nil {
+		address.PostalCode = *input.PostalCode
+	}
+	if input.Phone != nil {
+		address.Phone = *input.Phone
+	}
+	if input.Notes != nil {
+		address.Notes = *input.Notes
+	}
+
+	v := validator.New()
+
+	if data.ValidateAddress(v, address); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Addresses.Update(address)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"address": address}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAddressHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Addresses.Delete(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrAddressInUse):
+			app.errorResponse(w, r, http.StatusConflict, "the address has deliveries booked to it and can't be deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "address deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
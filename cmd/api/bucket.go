@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) showBucketHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	toys, err := app.models.Bucket.GetToys(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"bucket": toys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addToBucketHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ToyID int64 `json:"toy_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.ToyID > 0, "toy_id", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Bucket.Add(user.ID, input.ToyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("toy_id", "toy does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrAlreadyInBucket):
+			v.AddError("toy_id", "toy is already in your bucket")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrToyUnavailable):
+			app.toyUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrToyOnHold):
+			app.toyOnHoldResponse(w, r)
+		case errors.Is(err, data.ErrNoActivePlan):
+			app.noActivePlanResponse(w, r)
+		case errors.Is(err, data.ErrPlanLimitExceeded):
+			app.planLimitExceededResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	toys, err := app.models.Bucket.GetToys(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"bucket": toys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeFromBucketHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Bucket.Remove(user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "toy removed from bucket"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) submitBucketHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	checkout, err := app.models.Bucket.Submit(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEmptyBucket):
+			v := validator.New()
+			v.AddError("bucket", "must contain at least one toy")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/checkouts/%d", checkout.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"checkout": checkout}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
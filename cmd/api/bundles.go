@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+	"time"
+)
+
+func (app *application) listBundlesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title     string
+		Available bool
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.Available = app.readBool(qs, "available", false, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "title", "value", "-id", "-title", "-value"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	bundles, metadata, err := app.models.Bundles.GetAll(input.Title, input.Available, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"bundles": bundles, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createBundleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title       string   `json:"title"`
+		Description string   `json:"desc"`
+		Images      []string `json:"images"`
+		ToyIDs      []int64  `json:"toy_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bundle := &data.Bundle{
+		Title:       input.Title,
+		Description: input.Description,
+		Images:      input.Images,
+		ToyIDs:      input.ToyIDs,
+	}
+
+	v := validator.New()
+
+	if data.ValidateBundle(v, bundle); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Bundles.Insert(bundle)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownToy):
+			v.AddError("toy_ids", "some of the toys do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/bundles/%d", bundle.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"bundle": bundle}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showBundleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	bundle, err := app.models.Bundles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	bundle.Toys, err = app.models.Toys.GetByIDs(bundle.ToyIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"bundle": bundle}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateBundleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	bundle, err := app.models.Bundles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Title       *string   `json:"title"`
+		Description *string   `json:"desc"`
+		Images      *[]string `json:"images"`
+		ToyIDs      *[]int64  `json:"toy_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Title != nil {
+		bundle.Title = *input.Title
+	}
+	if input.Description != nil {
+		bundle.Description = *input.Description
+	}
+	if input.Images != nil {
+		bundle.Images = *input.Images
+	}
+	if input.ToyIDs != nil {
+		bundle.ToyIDs = *input.ToyIDs
+	}
+
+	v := validator.New()
+
+	if data.ValidateBundle(v, bundle); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Bundles.Update(bundle)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownToy):
+			v.AddError("toy_ids", "some of the toys do not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"bundle": bundle}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteBundleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Bundles.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "bundle deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkoutBundleHandler lends every toy of a bundle to a user at once. If any
+// of them can't be checked out, none are, and the error names the toy.
+func (app *application) checkoutBundleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		UserID  int64     `json:"user_id"`
+		ChildID int64     `json:"child_id"`
+		DueDate time.Time `json:"due_date"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bundle, err := app.models.Bundles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.ChildID >= 0, "child_id", "must not be negative")
+	for _, toyID := range bundle.ToyIDs {
+		data.ValidateLoan(v, &data.Loan{ToyID: toyID, UserID: input.UserID, DueDate: input.DueDate})
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loans, err := app.models.Bundles.Checkout(bundle.ID, input.UserID, input.ChildID, input.DueDate)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownUser):
+			v.AddError("user_id", "user does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownChild):
+			v.AddError("child_id", "child does not exist for this user")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrBundleEmpty):
+			app.errorResponse(w, r, http.StatusConflict, "the bundle has no toys")
+		case errors.Is(err, data.ErrRecordNotFound) && !errors.As(err, new(*data.ToyCheckoutError)):
+			app.notFoundResponse(w, r)
+		default:
+			app.toyCheckoutFailedResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"loans": loans}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+	"time"
+)
+
+func (app *application) listDeliverySlotsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	date := app.readDate(r.URL.Query(), "date", today, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	slots, err := app.models.DeliverySlots.GetAllBetween(date, date.AddDate(0, 0, 1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"slots": slots}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createDeliverySlotHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+		Capacity int       `json:"capacity"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	slot := &data.DeliverySlot{
+		StartsAt: input.StartsAt,
+		EndsAt:   input.EndsAt,
+		Capacity: input.Capacity,
+	}
+
+	v := validator.New()
+
+	if data.ValidateDeliverySlot(v, slot); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.DeliverySlots.Insert(slot)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/delivery-slots/%d", slot.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"slot": slot}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showDeliverySlotHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	slot, err := app.models.DeliverySlots.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"slot": slot}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateDeliverySlotHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	slot, err := app.models.DeliverySlots.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		Capacity *int       `json:"capacity"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.StartsAt != nil {
+		slot.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		slot.EndsAt = *input.EndsAt
+	}
+	if input.Capacity != nil {
+		slot.Capacity = *input.Capacity
+	}
+
+	v := validator.New()
+
+	if data.ValidateDeliverySlot(v, slot); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.DeliverySlots.Update(slot)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrSlotOverbooked):
+			v.AddError("capacity", "must not be less than the number of bookings in the slot")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"slot": slot}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Kind       string `json:"kind"`
+		SlotID     int64  `json:"slot_id"`
+		AddressID  int64  `json:"address_id"`
+		CheckoutID int64  `json:"checkout_id"`
+		LoanID     int64  `json:"loan_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	delivery := &data.Delivery{
+		Kind:       input.Kind,
+		UserID:     user.ID,
+		SlotID:     input.SlotID,
+		AddressID:  input.AddressID,
+		CheckoutID: input.CheckoutID,
+		LoanID:     input.LoanID,
+	}
+
+	v := validator.New()
+
+	if data.ValidateDelivery(v, delivery); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Deliveries.Insert(delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownSlot):
+			v.AddError("slot_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownAddress):
+			v.AddError("address_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound) && delivery.Kind == data.DeliveryDropOff:
+			v.AddError("checkout_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("loan_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrSlotFull):
+			app.errorResponse(w, r, http.StatusConflict, "the delivery slot is fully booked")
+		case errors.Is(err, data.ErrSlotClosed):
+			app.errorResponse(w, r, http.StatusConflict, "the delivery slot has already started")
+		case errors.Is(err, data.ErrNotDeliverable) && delivery.Kind == data.DeliveryDropOff:
+			app.errorResponse(w, r, http.StatusConflict, "the checkout request has been rejected")
+		case errors.Is(err, data.ErrNotDeliverable):
+			app.errorResponse(w, r, http.StatusConflict, "the loan has already been returned")
+		case errors.Is(err, data.ErrAlreadyScheduled):
+			app.errorResponse(w, r, http.StatusConflict, "a delivery is already scheduled for this")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/deliveries/%d", delivery.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"delivery": delivery}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID int64
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	user := app.contextGetUser(r)
+
+	permitted, err := app.userHasPermission(user, "deliveries:manage")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Dispatchers see every user's bookings unless they ask for a single user.
+	input.UserID = user.ID
+	if permitted {
+		input.UserID = int64(app.readInt(qs, "user_id", 0, v))
+	}
+	input.Status = app.readString(qs, "status", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	if input.Status != "" {
+		data.ValidateDeliveryStatus(v, input.Status)
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	deliveries, metadata, err := app.models.Deliveries.GetAll(input.UserID, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	delivery, err := app.models.Deliveries.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if delivery.UserID != user.ID {
+		permitted, err := app.userHasPermission(user, "deliveries:manage")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permitted {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) cancelDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	delivery, err := app.models.Deliveries.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if delivery.UserID != user.ID {
+		permitted, err := app.userHasPermission(user, "deliveries:manage")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permitted {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	err = app.models.Deliveries.Cancel(delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDeliveryCompleted):
+			app.errorResponse(w, r, http.StatusConflict, "the delivery has already been completed")
+		case errors.Is(err, data.ErrSlotClosed):
+			app.errorResponse(w, r, http.StatusConflict, "the delivery slot has already started")
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCourierJobsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	date := app.readDate(qs, "date", today, v)
+	status := app.readString(qs, "status", "")
+
+	if status != "" {
+		data.ValidateDeliveryStatus(v, status)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	jobs, err := app.models.Deliveries.GetJobs(date, date.AddDate(0, 0, 1), status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"jobs": jobs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCourierJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(validator.PermittedValue(input.Status, data.DeliveryDelivered, data.DeliveryFailed), "status", "must be delivered or failed")
+	v.Check(input.Status != data.DeliveryFailed || input.Note != "", "note", "must be provided when the job failed")
+	v.Check(len(input.Note) <= 500, "note", "must not be more than 500 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	job, err := app.models.Deliveries.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	courier := app.contextGetUser(r)
+
+	err = app.models.Deliveries.Complete(job, input.Status, courier.ID, input.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDeliveryCompleted):
+			app.errorResponse(w, r, http.StatusConflict, "the job has already been completed")
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"oynas/internal/data"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -39,7 +41,15 @@ func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *htt
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+// editConflictResponse reports that a record changed since the client read
+// it. A client that sent If-Match gets 412, since its precondition failed.
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-Match") != "" {
+		message := "the resource has changed since it was fetched"
+		app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+		return
+	}
+
 	message := "edit conflict"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
@@ -79,3 +89,76 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) toyUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the toy is not available for checkout right now"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) loanReturnedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the loan has already been returned"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) alreadyOnWaitListResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you are already on the wait list for this toy"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) toyOnHoldResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the toy is being held for the next user on its wait list"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) planLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your plan doesn't allow any more toys at the same time"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) checkoutProcessedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the checkout request has already been processed"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// toyCheckoutFailedResponse sends the response for an error that kept the
+// toys of a checkout from being lent, naming the toy at fault when there is
+// one. Any other error is a server error.
+func (app *application) toyCheckoutFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	toy := "a toy"
+	var toyErr *data.ToyCheckoutError
+	if errors.As(err, &toyErr) {
+		toy = fmt.Sprintf("toy %d", toyErr.ToyID)
+	}
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.errorResponse(w, r, http.StatusConflict, toy+" no longer exists")
+	case errors.Is(err, data.ErrToyUnavailable):
+		app.errorResponse(w, r, http.StatusConflict, toy+" is not available for checkout right now")
+	case errors.Is(err, data.ErrToyOnHold):
+		app.errorResponse(w, r, http.StatusConflict, toy+" is being held for the next user on its wait list")
+	case errors.Is(err, data.ErrUnknownUser):
+		app.errorResponse(w, r, http.StatusConflict, "the borrower no longer exists")
+	case errors.Is(err, data.ErrPlanLimitExceeded):
+		app.planLimitExceededResponse(w, r)
+	case errors.Is(err, data.ErrNoActivePlan):
+		app.noActivePlanResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) noActivePlanResponse(w http.ResponseWriter, r *http.Request) {
+	message := "an active subscription plan is required"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) insufficientFundsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your balance is too low for this operation"
+	app.errorResponse(w, r, http.StatusPaymentRequired, message)
+}
+
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the idempotency key has already been used for a different request"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
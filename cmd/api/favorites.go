@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) showFavoriteCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	categories, err := app.models.Favorites.Get(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"favorite_categories": categories}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateFavoriteCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Categories []string `json:"categories"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	taxonomy, err := app.models.Taxonomy.Load()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateFavoriteCategories(v, input.Categories, taxonomy); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Favorites.Set(user.ID, input.Categories)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"favorite_categories": input.Categories}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
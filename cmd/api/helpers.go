@@ -8,9 +8,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"oynas/internal/data"
 	"oynas/internal/validator"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type envelope map[string]any
@@ -131,6 +133,46 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// readDate reads a calendar day in the YYYY-MM-DD format and returns its
+// midnight in the server's time zone.
+func (app *application) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		v.AddError(key, "must be a date in the YYYY-MM-DD format")
+		return defaultValue
+	}
+
+	return date
+}
+
+func (app *application) readIdempotencyKey(r *http.Request, v *validator.Validator) string {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	data.ValidateIdempotencyKey(v, key)
+	return key
+}
+
 func (app *application) rateLimitExceedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -149,3 +191,26 @@ func (app *application) background(fn func()) {
 
 	fn()
 }
+
+// versionETag is the strong ETag of a record with a version column.
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch reports whether a request may change a resource with the given
+// ETag: it has no If-Match header, or one listing the ETag or "*".
+func ifMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/images"
+	"oynas/internal/storage"
+	"oynas/internal/validator"
+	"strings"
+)
+
+// maxImagesPerUpload is the number of "image" parts accepted in one upload.
+const maxImagesPerUpload = 5
+
+type uploadedImage struct {
+	URL         string            `json:"url"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Size        int               `json:"size"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+}
+
+// storeImages reads the images of a multipart upload, checks them and stores
+// them with their thumbnails. At most maxFiles images are accepted. Images
+// are stored under the hash of their content, so their URLs never change and
+// uploading one twice stores it once. It writes the error response itself and
+// returns false if anything fails.
+func (app *application) storeImages(w http.ResponseWriter, r *http.Request, maxFiles int) ([]uploadedImage, bool) {
+	maxBytes := app.config.storage.maxImageBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxImagesPerUpload*maxBytes+1_048_576)
+
+	err := r.ParseMultipartForm(1_048_576)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, errors.New("body must be multipart/form-data"))
+		}
+		return nil, false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["image"]
+
+	v := validator.New()
+
+	v.Check(len(files) > 0, "image", "must be provided")
+	v.Check(len(files) <= maxFiles, "image", fmt.Sprintf("must not be more than %d files", maxFiles))
+	for _, file := range files {
+		v.Check(file.Size <= maxBytes, "image", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, false
+	}
+
+	uploaded := []uploadedImage{}
+
+	for _, file := range files {
+		f, err := file.Open()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+
+		img, err := images.Process(content)
+		if err != nil {
+			switch {
+			case errors.Is(err, images.ErrUnsupportedFormat):
+				v.AddError("image", fmt.Sprintf("%s must be a JPEG, PNG or GIF image", file.Filename))
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, images.ErrTooLarge):
+				v.AddError("image", fmt.Sprintf("%s must not have more than %d pixels", file.Filename, images.MaxPixels))
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return nil, false
+		}
+
+		sum := sha256.Sum256(content)
+		prefix := "toys/" + hex.EncodeToString(sum[:16])
+
+		image := uploadedImage{
+			ContentType: img.ContentType,
+			Width:       img.Width,
+			Height:      img.Height,
+			Size:        len(content),
+			Thumbnails:  map[string]string{},
+		}
+
+		for _, variant := range append([]images.Variant{img.Original}, img.Thumbnails...) {
+			key := fmt.Sprintf("%s/%s.%s", prefix, variant.Name, variant.Extension)
+
+			err = app.storage.Put(r.Context(), key, bytes.NewReader(variant.Data), variant.ContentType)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return nil, false
+			}
+
+			if variant.Name == img.Original.Name {
+				image.URL = app.storage.URL(key)
+			} else {
+				image.Thumbnails[variant.Name] = app.storage.URL(key)
+			}
+		}
+
+		uploaded = append(uploaded, image)
+	}
+
+	return uploaded, true
+}
+
+func (app *application) uploadImagesHandler(w http.ResponseWriter, r *http.Request) {
+	uploaded, ok := app.storeImages(w, r, maxImagesPerUpload)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"images": uploaded}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) uploadToyImagesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	toy, err := app.models.Toys.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The toy is checked before anything is stored, so that a toy that can't
+	// be saved leaves no stored images behind.
+	taxonomy, err := app.models.Taxonomy.Load()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateToy(v, toy, taxonomy)
+	v.Check(len(toy.Images) < data.MaxToyImages, "image", fmt.Sprintf("toy already has %d images", data.MaxToyImages))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	maxFiles := data.MaxToyImages - len(toy.Images)
+	if maxFiles > maxImagesPerUpload {
+		maxFiles = maxImagesPerUpload
+	}
+
+	uploaded, ok := app.storeImages(w, r, maxFiles)
+	if !ok {
+		return
+	}
+
+	if toy.Thumbnails == nil {
+		toy.Thumbnails = map[string]map[string]string{}
+	}
+	for _, image := range uploaded {
+		toy.Images = append(toy.Images, image.URL)
+		toy.Thumbnails[image.URL] = image.Thumbnails
+	}
+
+	err = app.models.Toys.Update(toy, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"images": uploaded, "toy": toy}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveImageHandler serves stored images to anyone, since they are linked
+// from pages and img tags that carry no credentials. Stored images never
+// change, so they can be cached for good.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+
+	object, err := app.storage.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer object.Content.Close()
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(w, r, key, object.ModTime, object.Content)
+}
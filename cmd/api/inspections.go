@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) createInspectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Condition    string   `json:"condition"`
+		MissingParts []string `json:"missing_parts"`
+		Photos       []string `json:"photos"`
+		Notes        string   `json:"notes"`
+		Fee          int64    `json:"fee"`
+		UnitStatus   string   `json:"unit_status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loan, err := app.models.Loans.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	report := &data.DamageReport{
+		InspectorID:  user.ID,
+		Condition:    input.Condition,
+		MissingParts: input.MissingParts,
+		Photos:       input.Photos,
+		Notes:        input.Notes,
+		Fee:          input.Fee,
+		UnitStatus:   input.UnitStatus,
+	}
+
+	if report.MissingParts == nil {
+		report.MissingParts = []string{}
+	}
+	if report.Photos == nil {
+		report.Photos = []string{}
+	}
+	if report.UnitStatus == "" {
+		report.UnitStatus = data.UnitInStock
+	}
+
+	v := validator.New()
+
+	if data.ValidateDamageReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.DamageReports.Insert(report, loan)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLoanNotReturned):
+			app.errorResponse(w, r, http.StatusConflict, "the loan must be returned before it can be inspected")
+		case errors.Is(err, data.ErrAlreadyInspected):
+			app.errorResponse(w, r, http.StatusConflict, "the loan has already been inspected")
+		case errors.Is(err, data.ErrUnitRented):
+			app.errorResponse(w, r, http.StatusConflict, "the unit has been rented out again since it was returned")
+		case errors.Is(err, data.ErrIdempotencyKeyReused):
+			app.errorResponse(w, r, http.StatusConflict, "a different damage fee has already been charged for the loan")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if report.UnitStatus == data.UnitInStock {
+		app.offerToyToNextInLine(report.ToyID)
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"inspection": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listToyInspectionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	reports, err := app.models.DamageReports.GetAllForToy(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"inspections": reports}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
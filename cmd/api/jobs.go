@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"oynas/internal/data"
+	"oynas/internal/jobs"
+	"strconv"
+	"time"
+)
+
+func (app *application) startJobs() *jobs.Runner {
+	runner := jobs.New(app.logger)
+
+	runner.Every("wait-list-holds", app.config.waitList.sweepInterval, app.expireWaitListHoldsJob)
+
+	if app.config.lateFees.dailyRate > 0 {
+		runner.Every("late-fees", app.config.lateFees.interval, app.chargeLateFeesJob)
+	}
+
+	if app.config.archive.purgeAfter > 0 {
+		runner.Every("toy-purge", app.config.archive.purgeInterval, app.purgeArchivedToysJob)
+	}
+
+	runner.Start()
+
+	return runner
+}
+
+// chargeLateFeesJob charges the late fees owed on every overdue loan and
+// emails each borrower who was charged.
+func (app *application) chargeLateFeesJob(ctx context.Context) error {
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     100,
+		Sort:         "id",
+		SortSafelist: []string{"id"},
+	}
+
+	for {
+		loans, metadata, err := app.models.Loans.GetOverdue(filters)
+		if err != nil {
+			return err
+		}
+
+		for _, loan := range loans {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			charges, err := app.models.Loans.ChargeLateFees(loan.ID, app.config.lateFees.dailyRate)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"loan_id": strconv.FormatInt(loan.ID, 10)})
+				continue
+			}
+
+			if len(charges) == 0 {
+				continue
+			}
+
+			var fee int64
+			for _, charge := range charges {
+				fee -= charge.Amount
+			}
+
+			data := map[string]any{
+				"userName":    loan.UserName,
+				"toyTitle":    loan.ToyTitle,
+				"dueDate":     loan.DueDate.Format(time.RFC1123),
+				"daysOverdue": loan.DaysOverdue,
+				"fee":         fee,
+				"balance":     charges[len(charges)-1].BalanceAfter,
+			}
+
+			err = app.mailer.Send(loan.UserEmail, "late_fee.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"loan_id": strconv.FormatInt(loan.ID, 10)})
+			}
+		}
+
+		if filters.Page >= metadata.LastPage {
+			return nil
+		}
+		filters.Page++
+	}
+}
+
+// expireWaitListHoldsJob offers the toys whose holds have lapsed to the next
+// users in line. A toy with several lapsed holds is offered until its free
+// units are all held again or its queue is empty.
+func (app *application) expireWaitListHoldsJob(ctx context.Context) error {
+	toyIDs, err := app.models.WaitList.GetLapsedHolds()
+	if err != nil {
+		return err
+	}
+
+	for _, toyID := range toyIDs {
+		for app.offerToyToNextInLine(toyID) {
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// purgeArchivedToysJob permanently deletes the toys that have been archived
+// for longer than the configured retention period.
+func (app *application) purgeArchivedToysJob(ctx context.Context) error {
+	purged, err := app.models.Toys.PurgeArchived(time.Now().Add(-app.config.archive.purgeAfter))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.logger.PrintInfo("purged archived toys", map[string]string{"count": strconv.FormatInt(purged, 10)})
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+	"strconv"
+)
+
+func (app *application) showBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	reconciliation, err := app.models.Ledger.Reconcile(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !reconciliation.InSync {
+		app.logger.PrintError(errors.New("balance does not match ledger"), map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"balance": reconciliation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Kind string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Kind = app.readString(qs, "kind", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "amount", "-id", "-created_at", "-amount"}
+
+	if input.Kind != "" {
+		v.Check(validator.PermittedValue(input.Kind, data.LedgerTopUp, data.LedgerCharge, data.LedgerRefund, data.LedgerAdjustment), "kind", "must be top_up, charge, refund or adjustment")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	entries, metadata, err := app.models.Ledger.GetAllForUser(user.ID, input.Kind, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transactions": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createLedgerEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID         int64  `json:"user_id"`
+		Kind           string `json:"kind"`
+		Amount         int64  `json:"amount"`
+		Description    string `json:"description"`
+		AllowOverdraft bool   `json:"allow_overdraft"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	entry := &data.LedgerEntry{
+		UserID:         input.UserID,
+		Kind:           input.Kind,
+		Amount:         input.Amount,
+		Description:    input.Description,
+		IdempotencyKey: app.readIdempotencyKey(r, v),
+	}
+
+	if data.ValidateLedgerEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.models.Ledger.Post(entry, input.AllowOverdraft)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownUser):
+			v.AddError("user_id", "user does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInsufficientFunds):
+			app.insufficientFundsResponse(w, r)
+		case errors.Is(err, data.ErrIdempotencyKeyReused):
+			app.idempotencyKeyReusedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+
+	err = app.writeJSON(w, status, envelope{"transaction": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+	"time"
+)
+
+func (app *application) createLoanHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ToyID   int64     `json:"toy_id"`
+		UserID  int64     `json:"user_id"`
+		ChildID int64     `json:"child_id"`
+		DueDate time.Time `json:"due_date"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loan := &data.Loan{
+		ToyID:   input.ToyID,
+		UserID:  input.UserID,
+		ChildID: input.ChildID,
+		DueDate: input.DueDate,
+	}
+
+	v := validator.New()
+
+	v.Check(loan.ChildID >= 0, "child_id", "must not be negative")
+	if data.ValidateLoan(v, loan); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Loans.Insert(loan)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("toy_id", "toy does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownUser):
+			v.AddError("user_id", "user does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownChild):
+			v.AddError("child_id", "child does not exist for this user")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrToyUnavailable):
+			app.toyUnavailableResponse(w, r)
+		case errors.Is(err, data.ErrToyOnHold):
+			app.toyOnHoldResponse(w, r)
+		case errors.Is(err, data.ErrNoActivePlan):
+			app.noActivePlanResponse(w, r)
+		case errors.Is(err, data.ErrPlanLimitExceeded):
+			app.planLimitExceededResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/loans/%d", loan.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"loan": loan}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) returnLoanHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	loan, err := app.models.Loans.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Loans.MarkReturned(loan)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLoanReturned):
+			app.loanReturnedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loan": loan}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listLoansHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		UserID int64
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	user := app.contextGetUser(r)
+
+	input.UserID = int64(app.readInt(qs, "user_id", int(user.ID), v))
+	input.Status = app.readString(qs, "status", data.LoanStatusActive)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "due_date", "returned_at", "-id", "-created_at", "-due_date", "-returned_at"}
+
+	data.ValidateLoanStatus(v, input.Status)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.UserID != user.ID {
+		permitted, err := app.userHasPermission(user, "loans:read")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	loans, metadata, err := app.models.Loans.GetAllForUser(input.UserID, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loans": loans, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOverdueLoansHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "due_date")
+	input.Filters.SortSafelist = []string{"id", "due_date", "-id", "-due_date"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loans, metadata, err := app.models.Loans.GetOverdue(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loans": loans, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
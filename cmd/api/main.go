@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"oynas/internal/data"
 	"oynas/internal/jsonlog"
 	"oynas/internal/mailer"
+	"oynas/internal/storage"
 	"strings"
 	"sync"
 	"time"
@@ -41,14 +44,32 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	waitList struct {
+		holdTTL       time.Duration
+		sweepInterval time.Duration
+	}
+	lateFees struct {
+		dailyRate float64
+		interval  time.Duration
+	}
+	archive struct {
+		purgeAfter    time.Duration
+		purgeInterval time.Duration
+	}
+	storage struct {
+		dir           string
+		baseURL       string
+		maxImageBytes int64
+	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Store
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -71,6 +92,19 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "cfc3eccc70fd94", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.alexedwards.net>", "SMTP sender")
 
+	flag.DurationVar(&cfg.waitList.holdTTL, "waitlist-hold-ttl", 48*time.Hour, "How long a freed toy is held for the next user on its wait list")
+	intervalFlag(&cfg.waitList.sweepInterval, "waitlist-sweep-interval", 5*time.Minute, "How often lapsed wait list holds are offered to the next user in line")
+
+	flag.Float64Var(&cfg.lateFees.dailyRate, "late-fee-daily-rate", 0.02, "Share of a toy's value charged for each day it is overdue (0 disables late fees)")
+	intervalFlag(&cfg.lateFees.interval, "late-fee-interval", time.Hour, "How often overdue loans are checked for late fees")
+
+	flag.DurationVar(&cfg.archive.purgeAfter, "toy-purge-after", 90*24*time.Hour, "How long archived toys are kept before they are permanently deleted (0 disables purging)")
+	intervalFlag(&cfg.archive.purgeInterval, "toy-purge-interval", 24*time.Hour, "How often archived toys are checked for purging")
+
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory uploaded images are stored in")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "/v1/images", "URL prefix uploaded images are linked with")
+	flag.Int64Var(&cfg.storage.maxImageBytes, "image-max-bytes", 10<<20, "Maximum size of an uploaded image in bytes")
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (separated by space)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
@@ -89,11 +123,17 @@ func main() {
 
 	logger.PrintInfo("connection pool established", nil)
 
+	store, err := storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  data.NewModels(db),
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage: store,
 	}
 
 	err = app.serve()
@@ -104,6 +144,26 @@ func main() {
 	// logger.PrintFatal(err, nil)
 }
 
+// intervalFlag defines a duration flag for how often a background job runs.
+// Jobs can't run at a zero or negative interval, so such values are rejected
+// when the flags are parsed.
+func intervalFlag(p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+
+	flag.Func(name, fmt.Sprintf("%s (default %s)", usage, value), func(val string) error {
+		interval, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return errors.New("must be positive")
+		}
+
+		*p = interval
+		return nil
+	})
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
 
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) listPlansHandler(w http.ResponseWriter, r *http.Request) {
+	plans, err := app.models.Plans.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"plans": plans}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	subscription, err := app.models.Plans.GetSubscription(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoActivePlan):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"subscription": subscription}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PlanID int64 `json:"plan_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	idempotencyKey := app.readIdempotencyKey(r, v)
+
+	if v.Check(input.PlanID > 0, "plan_id", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	subscription, err := app.models.Plans.Subscribe(user.ID, input.PlanID, idempotencyKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("plan_id", "plan does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrAlreadySubscribed):
+			v.AddError("plan_id", "you are already subscribed to this plan")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrPlanLimitExceeded):
+			v.AddError("plan_id", "return some toys before moving to this plan")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInsufficientFunds):
+			app.insufficientFundsResponse(w, r)
+		case errors.Is(err, data.ErrIdempotencyKeyReused):
+			app.idempotencyKeyReusedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"subscription": subscription}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
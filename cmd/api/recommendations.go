@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/recommend"
+	"oynas/internal/validator"
+	"time"
+)
+
+type recommendation struct {
+	Toy     *data.Toy `json:"toy"`
+	Score   float64   `json:"score"`
+	Reasons []string  `json:"reasons"`
+}
+
+func recommendItem(toy *data.Toy) recommend.Item {
+	return recommend.Item{
+		ID:         toy.ID,
+		Skills:     toy.Skills,
+		Categories: toy.Categories,
+		MinAge:     toy.RecommendedAge.Min,
+		MaxAge:     toy.RecommendedAge.Max,
+	}
+}
+
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	childID := app.readInt(qs, "child_id", 0, v)
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "maximum is 50")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	profile := recommend.Profile{}
+
+	if childID != 0 {
+		child, err := app.models.Children.GetForUser(int64(childID), user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("child_id", "does not exist")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		profile.ChildAge = child.AgeInMonths(time.Now())
+		profile.Interests = child.Interests
+	}
+
+	rented, err := app.models.Toys.GetRentedBy(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	candidates, err := app.models.Toys.GetNotRentedBy(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Favorites are the categories the user picked, not ones guessed from
+	// the history, which is already weighed on its own.
+	profile.FavoriteCategories, err = app.models.Favorites.Get(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, toy := range rented {
+		profile.History = append(profile.History, recommendItem(toy))
+	}
+
+	items := make([]recommend.Item, 0, len(candidates))
+	for _, toy := range candidates {
+		items = append(items, recommendItem(toy))
+	}
+
+	ranked := recommend.Rank(profile, items, recommend.DefaultWeights, limit)
+
+	ids := make([]int64, 0, len(ranked))
+	for _, rec := range ranked {
+		ids = append(ids, rec.ID)
+	}
+
+	toys, err := app.models.Toys.GetByIDs(ids)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	byID := make(map[int64]*data.Toy, len(toys))
+	for _, toy := range toys {
+		byID[toy.ID] = toy
+	}
+
+	recommendations := []recommendation{}
+	for _, rec := range ranked {
+		toy, ok := byID[rec.ID]
+		if !ok {
+			continue
+		}
+		recommendations = append(recommendations, recommendation{Toy: toy, Score: rec.Score, Reasons: rec.Reasons})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recommendations": recommendations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -16,14 +16,103 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/toy/:id", app.requirePermission("toys:read", app.showToyHandler))
 	router.HandlerFunc(http.MethodPatch, "/v1/toy/:id", app.requirePermission("toys:write", app.updateToyHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/toy/:id", app.requirePermission("toys:write", app.deleteToyHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/toy/:id/restore", app.requirePermission("toys:admin", app.restoreToyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/toy/:id/history", app.requirePermission("toys:write", app.listToyHistoryHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/toy/:id/rollback", app.requirePermission("toys:admin", app.rollbackToyHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/toys", app.requirePermission("toys:read", app.listToysHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/toys/suggest", app.requirePermission("toys:read", app.suggestToysHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/bundles", app.requirePermission("toys:read", app.listBundlesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/bundles", app.requirePermission("toys:write", app.createBundleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/bundles/:id", app.requirePermission("toys:read", app.showBundleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/bundles/:id", app.requirePermission("toys:write", app.updateBundleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/bundles/:id", app.requirePermission("toys:write", app.deleteBundleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/bundles/:id/checkout", app.requirePermission("loans:write", app.checkoutBundleHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/manufacturers", app.requirePermission("toys:read", app.listManufacturersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/manufacturers", app.requirePermission("toys:write", app.createManufacturerHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/manufacturers/:id", app.requirePermission("toys:read", app.showManufacturerHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/manufacturers/:id", app.requirePermission("toys:write", app.updateManufacturerHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/manufacturers/:id", app.requirePermission("toys:write", app.deleteManufacturerHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/taxonomy/:vocabulary", app.requirePermission("toys:read", app.listTaxonomyTermsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/taxonomy/:vocabulary", app.requirePermission("taxonomy:write", app.createTaxonomyTermHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/taxonomy/:vocabulary/:id", app.requirePermission("taxonomy:write", app.updateTaxonomyTermHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/taxonomy/:vocabulary/:id", app.requirePermission("taxonomy:write", app.deleteTaxonomyTermHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/toy/:id/images", app.requirePermission("toys:write", app.uploadToyImagesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/images", app.requirePermission("toys:write", app.uploadImagesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/images/*key", app.serveImageHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/toy/:id/comment", app.requirePermission("toys:comment", app.createCommentHandler))
 
+	router.HandlerFunc(http.MethodGet, "/v1/toy/:id/inspections", app.requirePermission("toys:inspect", app.listToyInspectionsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/toy/:id/units", app.requirePermission("units:read", app.listToyUnitsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/toy/:id/units", app.requirePermission("units:write", app.createToyUnitHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/units/:id", app.requirePermission("units:read", app.showToyUnitHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/units/:id", app.requirePermission("units:write", app.updateToyUnitHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/toy/:id/waitlist", app.requirePermission("toys:read", app.joinWaitListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/toy/:id/waitlist", app.requirePermission("toys:read", app.showWaitListPositionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/toy/:id/waitlist", app.requirePermission("toys:read", app.leaveWaitListHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/loans", app.requirePermission("loans:write", app.createLoanHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/loans", app.requireActivatedUser(app.listLoansHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/loans/overdue", app.requirePermission("loans:read", app.listOverdueLoansHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/loans/:id/return", app.requirePermission("loans:write", app.returnLoanHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/loans/:id/inspection", app.requirePermission("toys:inspect", app.createInspectionHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/checkouts", app.requireActivatedUser(app.listCheckoutsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/checkouts/:id", app.requireActivatedUser(app.showCheckoutHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/checkouts/:id/approve", app.requirePermission("loans:write", app.approveCheckoutHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/checkouts/:id/reject", app.requirePermission("loans:write", app.rejectCheckoutHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationHandler)
 
+	router.HandlerFunc(http.MethodGet, "/v1/plans", app.listPlansHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/plan", app.requireActivatedUser(app.showSubscriptionHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/plan", app.requireActivatedUser(app.subscribeHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/balance", app.requireActivatedUser(app.showBalanceHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/transactions", app.requireActivatedUser(app.listTransactionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/ledger", app.requirePermission("ledger:write", app.createLedgerEntryHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/children", app.requireActivatedUser(app.listChildrenHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/children", app.requireActivatedUser(app.createChildHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/children/:id", app.requireActivatedUser(app.showChildHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/children/:id", app.requireActivatedUser(app.updateChildHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/children/:id", app.requireActivatedUser(app.deleteChildHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/favorite-categories", app.requireActivatedUser(app.showFavoriteCategoriesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/favorite-categories", app.requireActivatedUser(app.updateFavoriteCategoriesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/recommendations", app.requirePermission("toys:read", app.listRecommendationsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/bucket", app.requireActivatedUser(app.showBucketHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/bucket", app.requireActivatedUser(app.addToBucketHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/bucket/:id", app.requireActivatedUser(app.removeFromBucketHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/bucket/checkout", app.requireActivatedUser(app.submitBucketHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/addresses", app.requireActivatedUser(app.listAddressesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/addresses", app.requireActivatedUser(app.createAddressHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/addresses/:id", app.requireActivatedUser(app.updateAddressHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/addresses/:id", app.requireActivatedUser(app.deleteAddressHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/delivery-slots", app.requireActivatedUser(app.listDeliverySlotsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/delivery-slots", app.requirePermission("deliveries:manage", app.createDeliverySlotHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/delivery-slots/:id", app.requireActivatedUser(app.showDeliverySlotHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/delivery-slots/:id", app.requirePermission("deliveries:manage", app.updateDeliverySlotHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/deliveries", app.requireActivatedUser(app.createDeliveryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/deliveries", app.requireActivatedUser(app.listDeliveriesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/deliveries/:id", app.requireActivatedUser(app.showDeliveryHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/deliveries/:id/cancel", app.requireActivatedUser(app.cancelDeliveryHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/courier/jobs", app.requirePermission("deliveries:courier", app.listCourierJobsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/courier/jobs/:id", app.requirePermission("deliveries:courier", app.updateCourierJobHandler))
+
 	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
 
 }
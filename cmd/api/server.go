@@ -22,6 +22,8 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	runner := app.startJobs()
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -42,6 +44,7 @@ func (app *application) serve() error {
 		app.logger.PrintInfo("completing background tasks", map[string]string{
 			"addr": srv.Addr,
 		})
+		runner.Stop()
 		app.wg.Wait()
 		shutdownError <- nil
 
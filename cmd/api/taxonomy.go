@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+// readVocabularyParam maps the vocabulary of a taxonomy URL, "categories" or
+// "skills", to the vocabulary its terms are stored under.
+func (app *application) readVocabularyParam(r *http.Request) (string, bool) {
+	switch httprouter.ParamsFromContext(r.Context()).ByName("vocabulary") {
+	case "categories":
+		return data.VocabularyCategory, true
+	case "skills":
+		return data.VocabularySkill, true
+	default:
+		return "", false
+	}
+}
+
+func (app *application) listTaxonomyTermsHandler(w http.ResponseWriter, r *http.Request) {
+	vocabulary, ok := app.readVocabularyParam(r)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	terms, err := app.models.Taxonomy.GetAll(vocabulary)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"terms": terms}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createTaxonomyTermHandler(w http.ResponseWriter, r *http.Request) {
+	vocabulary, ok := app.readVocabularyParam(r)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Slug     string            `json:"slug"`
+		ParentID int64             `json:"parent_id"`
+		Labels   map[string]string `json:"labels"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	term := &data.TaxonomyTerm{
+		Vocabulary: vocabulary,
+		Slug:       input.Slug,
+		ParentID:   input.ParentID,
+		Labels:     input.Labels,
+	}
+
+	v := validator.New()
+
+	if data.ValidateTaxonomyTerm(v, term); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Taxonomy.Insert(term)
+	if err != nil {
+		app.taxonomyTermErrorResponse(w, r, v, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/taxonomy/%s/%d", httprouter.ParamsFromContext(r.Context()).ByName("vocabulary"), term.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"term": term}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateTaxonomyTermHandler(w http.ResponseWriter, r *http.Request) {
+	vocabulary, ok := app.readVocabularyParam(r)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	term, err := app.models.Taxonomy.Get(vocabulary, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Slug     *string           `json:"slug"`
+		ParentID *int64            `json:"parent_id"`
+		Labels   map[string]string `json:"labels"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Slug != nil {
+		term.Slug = *input.Slug
+	}
+	if input.ParentID != nil {
+		term.ParentID = *input.ParentID
+	}
+	if input.Labels != nil {
+		term.Labels = input.Labels
+	}
+
+	v := validator.New()
+
+	if data.ValidateTaxonomyTerm(v, term); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Taxonomy.Update(term)
+	if err != nil {
+		app.taxonomyTermErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"term": term}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTaxonomyTermHandler(w http.ResponseWriter, r *http.Request) {
+	vocabulary, ok := app.readVocabularyParam(r)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Taxonomy.Delete(vocabulary, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrTermInUse):
+			app.errorResponse(w, r, http.StatusConflict, "the term has toys or child terms and can't be deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "term deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) taxonomyTermErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateSlug):
+		v.AddError("slug", "a term with this slug already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownParent):
+		v.AddError("parent_id", "parent term does not exist in this vocabulary")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrTermCycle):
+		v.AddError("parent_id", "must not be the term itself or one of its descendants")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
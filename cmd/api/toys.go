@@ -6,20 +6,22 @@ import (
 	"net/http"
 	"oynas/internal/data"
 	"oynas/internal/validator"
+	"strings"
+	"time"
 )
 
 func (app *application) createToyHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title          string   `json:"title"`
-		Description    string   `json:"desc"`
-		Details        []string `json:"details"`
-		Skills         []string `json:"skills"`
-		Images         []string `json:"images"`
-		Categories     []string `json:"categories"`
-		RecommendedAge string   `json:"recAge"`
-		Manufacturer   string   `json:"manufac"`
-		Value          int64    `json:"value"`
-		IsAvailable    string   `json:"isAvailable"`
+		SKU            string         `json:"sku"`
+		Title          string         `json:"title"`
+		Description    string         `json:"desc"`
+		Details        []string       `json:"details"`
+		Skills         []string       `json:"skills"`
+		Images         []string       `json:"images"`
+		Categories     []string       `json:"categories"`
+		RecommendedAge *data.AgeRange `json:"recAge"`
+		ManufacturerID int64          `json:"manufacturer_id"`
+		Value          int64          `json:"value"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -29,27 +31,46 @@ func (app *application) createToyHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	toy := &data.Toy{
+		SKU:            input.SKU,
 		Title:          input.Title,
 		Description:    input.Description,
 		Details:        input.Details,
 		Skills:         input.Skills,
 		Images:         input.Images,
 		Categories:     input.Categories,
-		RecommendedAge: input.RecommendedAge,
-		Manufacturer:   input.Manufacturer,
+		ManufacturerID: input.ManufacturerID,
 		Value:          input.Value,
-		IsAvailable:    input.IsAvailable,
+	}
+
+	if input.RecommendedAge != nil {
+		toy.RecommendedAge = *input.RecommendedAge
+	}
+
+	taxonomy, err := app.models.Taxonomy.Load()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateToy(v, toy); !v.Valid() {
+	v.Check(input.RecommendedAge != nil, "recAge", "age must be provided")
+	if data.ValidateToy(v, toy, taxonomy); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Toys.Insert(toy)
+	err = app.models.Toys.Insert(toy, app.contextGetUser(r).ID)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrUnknownManufacturer):
+			v.AddError("manufacturer_id", "manufacturer does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a toy with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
 		return
 	}
 
@@ -76,10 +97,40 @@ func (app *application) showToyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	toy, err := app.models.Toys.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Archived toys are only shown to the admins who can restore them.
+	if toy.ArchivedAt != nil {
+		permitted, err := app.userHasPermission(app.contextGetUser(r), "toys:admin")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
 
 	comments, err := app.models.Comment.GetCommentsFromId(id, input.Text, input.Rating)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"toy": toy, "comments": comments}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(toy.Version))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"toy": toy, "comments": comments}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -104,15 +155,23 @@ func (app *application) updateToyHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Clients send the ETag they read the toy with, so that edits made
+	// since then aren't overwritten.
+	if !ifMatch(r, versionETag(toy.Version)) {
+		app.editConflictResponse(w, r)
+		return
+	}
+
 	var input struct {
-		Title          *string   `json:"title"`
-		Description    *string   `json:"desc"`
-		Details        *[]string `json:"details"`
-		Skills         *[]string `json:"skills"`
-		Categories     *[]string `json:"categories"`
-		RecommendedAge *string   `json:"recAge"`
-		Manufacturer   *string   `json:"manufacturer"`
-		Value          *int64    `json:"value"`
+		SKU            *string        `json:"sku"`
+		Title          *string        `json:"title"`
+		Description    *string        `json:"desc"`
+		Details        *[]string      `json:"details"`
+		Skills         *[]string      `json:"skills"`
+		Categories     *[]string      `json:"categories"`
+		RecommendedAge *data.AgeRange `json:"recAge"`
+		ManufacturerID *int64         `json:"manufacturer_id"`
+		Value          *int64         `json:"value"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -121,6 +180,9 @@ func (app *application) updateToyHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if input.SKU != nil {
+		toy.SKU = *input.SKU
+	}
 	if input.Title != nil {
 		toy.Title = *input.Title
 	}
@@ -139,26 +201,46 @@ func (app *application) updateToyHandler(w http.ResponseWriter, r *http.Request)
 	if input.RecommendedAge != nil {
 		toy.RecommendedAge = *input.RecommendedAge
 	}
-	if input.Manufacturer != nil {
-		toy.Manufacturer = *input.Manufacturer
+	if input.ManufacturerID != nil {
+		toy.ManufacturerID = *input.ManufacturerID
 	}
 	if input.Value != nil {
 		toy.Value = *input.Value
 	}
 
+	taxonomy, err := app.models.Taxonomy.Load()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	v := validator.New()
-	if data.ValidateToy(v, toy); !v.Valid() {
+	if data.ValidateToy(v, toy, taxonomy); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Toys.Update(toy)
+	err = app.models.Toys.Update(toy, app.contextGetUser(r).ID)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrUnknownManufacturer):
+			v.AddError("manufacturer_id", "manufacturer does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a toy with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"toy": toy}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(toy.Version))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"toy": toy}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -172,26 +254,54 @@ func (app *application) deleteToyHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.models.Toys.Delete(id)
+	err = app.models.Toys.Archive(id, app.contextGetUser(r).ID)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Toy deleted successfully"}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Toy archived successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
 
+func (app *application) restoreToyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	toy, err := app.models.Toys.Restore(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", versionETag(toy.Version))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"toy": toy}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 func (app *application) listToysHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		Title          string
-		Value          int64
-		From           int64
-		To             int64
-		Skills         []string
-		Categories     []string
-		RecommendedAge string
+		data.ToySearch
 		data.Filters
 	}
 
@@ -199,31 +309,136 @@ func (app *application) listToysHandler(w http.ResponseWriter, r *http.Request)
 
 	qs := r.URL.Query()
 
-	input.Title = app.readString(qs, "title", "")
+	input.Query = app.readString(qs, "q", app.readString(qs, "title", ""))
+	input.Language = app.readString(qs, "lang", data.SearchRussian)
 	input.Skills = app.readCSV(qs, "skills", []string{})
 	input.Categories = app.readCSV(qs, "categories", []string{})
-	input.Value = int64(app.readInt(qs, "value", 0, v))
-	input.From = int64(app.readInt(qs, "from", 0, v))
-	input.To = int64(app.readInt(qs, "to", 100000, v))
+	input.MinValue = int64(app.readInt(qs, "from", 0, v))
+	input.MaxValue = int64(app.readInt(qs, "to", 100000, v))
+	input.Age = app.readInt(qs, "age", -1, v)
+	input.ManufacturerID = int64(app.readInt(qs, "manufacturer_id", 0, v))
+	input.IncludeArchived = app.readBool(qs, "include_archived", false, v)
+	input.Fuzzy = app.readBool(qs, "fuzzy", false, v)
+	childID := app.readInt(qs, "child_id", 0, v)
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+
+	defaultSort := "id"
+	if input.Query != "" {
+		defaultSort = "relevance"
+	}
+
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	input.Filters.SortSafelist = []string{"relevance", "id", "title", "skills", "categories", "min_age_months", "value", "rating", "popularity", "-id", "-title", "-skills", "-categories", "-min_age_months", "-value", "-rating", "-popularity"}
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "skills", "categories", "recAge", "value", "from", "to", "-id", "-title", "-skills", "-categories", "-recAge", "-value"}
+	if qs.Has("age") {
+		v.Check(input.Age >= 0, "age", "must not be negative")
+		v.Check(input.Age <= 18*12, "age", "must not be more than 216 months")
+	}
 
+	data.ValidateToySearch(v, input.ToySearch)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	toys, metadata, err := app.models.Toys.GetAll(input.Title, input.Skills, input.Categories, input.Value, input.From, input.To, input.Filters)
+	if input.IncludeArchived {
+		permitted, err := app.userHasPermission(app.contextGetUser(r), "toys:admin")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permitted {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	// A child narrows the catalog down to toys for their age, unless an age
+	// is given, and puts toys matching their interests first.
+	if childID != 0 {
+		child, err := app.models.Children.GetForUser(int64(childID), app.contextGetUser(r).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("child_id", "does not exist")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !qs.Has("age") {
+			input.Age = child.AgeInMonths(time.Now())
+		}
+		input.BoostSkills = child.Interests
+	}
+
+	toys, metadata, err := app.models.Toys.GetAll(input.ToySearch, input.Filters)
+
+	// Misspelled queries find nothing by full-text search, so they are
+	// retried by similarity of titles and manufacturers. Pages past the end
+	// of the results are empty too, so only the first page of a search that
+	// matched nothing is retried; the response says so, and later pages are
+	// asked for with fuzzy=true.
+	if err == nil && !input.Fuzzy && metadata.TotalRecords == 0 && input.Query != "" && input.Filters.Cursor == "" && input.Filters.Page == 1 {
+		input.Fuzzy = true
+
+		toys, metadata, err = app.models.Toys.GetAll(input.ToySearch, input.Filters)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "must be a valid cursor")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	facets, err := app.models.Toys.GetFacets(input.ToySearch)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"toys": toys, "metadata": metadata, "facets": facets, "fuzzy": input.Fuzzy, "stemmed": input.Stemmed()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) suggestToysHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	prefix := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 5, v)
+
+	v.Check(prefix != "", "q", "must be provided")
+	v.Check(len(prefix) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Toys.Suggest(prefix, limit)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"toys": toys, "metadata": metadata}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"oynas/internal/data"
+	"oynas/internal/validator"
+)
+
+func (app *application) createToyUnitHandler(w http.ResponseWriter, r *http.Request) {
+	toyID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Serial    string `json:"serial"`
+		Barcode   string `json:"barcode"`
+		Condition string `json:"condition"`
+		Location  string `json:"location"`
+		Status    string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	unit := &data.ToyUnit{
+		ToyID:     toyID,
+		Serial:    input.Serial,
+		Barcode:   input.Barcode,
+		Condition: input.Condition,
+		Location:  input.Location,
+		Status:    input.Status,
+	}
+
+	if unit.Condition == "" {
+		unit.Condition = "new"
+	}
+	if unit.Status == "" {
+		unit.Status = data.UnitInStock
+	}
+
+	v := validator.New()
+
+	v.Check(unit.Status != data.UnitRented, "status", "units can only be rented through a loan")
+	if data.ValidateToyUnit(v, unit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Units.Insert(unit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateSerial):
+			v.AddError("serial", "a unit with this serial already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateBarcode):
+			v.AddError("barcode", "a unit with this barcode already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if unit.Status == data.UnitInStock {
+		app.offerToyToNextInLine(unit.ToyID)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/units/%d", unit.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"unit": unit}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listToyUnitsHandler(w http.ResponseWriter, r *http.Request) {
+	toyID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	status := app.readString(r.URL.Query(), "status", "")
+	if status != "" {
+		v.Check(validator.PermittedValue(status, data.UnitInStock, data.UnitRented, data.UnitCleaning, data.UnitRetired), "status", "must be in_stock, rented, cleaning or retired")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	units, err := app.models.Units.GetAllForToy(toyID, status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"units": units}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showToyUnitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	unit, err := app.models.Units.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"unit": unit}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateToyUnitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	unit, err := app.models.Units.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Serial    *string `json:"serial"`
+		Barcode   *string `json:"barcode"`
+		Condition *string `json:"condition"`
+		Location  *string `json:"location"`
+		Status    *string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if unit.Status == data.UnitRented {
+		v.AddError("status", "the unit is rented and can't be changed until it is returned")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	wasInStock := unit.Status == data.UnitInStock
+
+	if input.Serial != nil {
+		unit.Serial = *input.Serial
+	}
+	if input.Barcode != nil {
+		unit.Barcode = *input.Barcode
+	}
+	if input.Condition != nil {
+		unit.Condition = *input.Condition
+	}
+	if input.Location != nil {
+		unit.Location = *input.Location
+	}
+	if input.Status != nil {
+		unit.Status = *input.Status
+	}
+
+	v.Check(unit.Status != data.UnitRented, "status", "units can only be rented through a loan")
+	if data.ValidateToyUnit(v, unit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Units.Update(unit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateSerial):
+			v.AddError("serial", "a unit with this serial already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateBarcode):
+			v.AddError("barcode", "a unit with this barcode already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !wasInStock && unit.Status == data.UnitInStock {
+		app.offerToyToNextInLine(unit.ToyID)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"unit": unit}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"oynas/internal/data"
+	"strconv"
+	"time"
+)
+
+func (app *application) joinWaitListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	entry, err := app.models.WaitList.Join(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrAlreadyOnWaitList):
+			app.alreadyOnWaitListResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The toy may already be free, in which case the new entry can be
+	// offered the toy straight away.
+	app.offerToyToNextInLine(id)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"wait_list": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showWaitListPositionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	entry, err := app.models.WaitList.GetForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"wait_list": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) leaveWaitListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.WaitList.Leave(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// If the user was holding the toy, the hold passes on to the next user.
+	app.offerToyToNextInLine(id)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have left the wait list"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// offerToyToNextInLine offers a free unit of the toy to the next user on its
+// wait list, if there is one, and reports whether it did.
+func (app *application) offerToyToNextInLine(toyID int64) bool {
+	entry, err := app.models.WaitList.OfferNext(toyID, app.config.waitList.holdTTL)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, map[string]string{"toy_id": strconv.FormatInt(toyID, 10)})
+		}
+		return false
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"userName":   entry.UserName,
+			"toyID":      entry.ToyID,
+			"toyTitle":   entry.ToyTitle,
+			"holdExpiry": entry.HoldExpiry.Format(time.RFC1123),
+		}
+
+		err := app.mailer.Send(entry.UserEmail, "toy_available.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	return true
+}
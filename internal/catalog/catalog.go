@@ -0,0 +1,374 @@
+// Package catalog reads and writes toy catalogs as CSV or JSON Lines, for
+// bulk imports and exports. Both formats carry the same fields; in CSV, list
+// fields are separated by "|", and a "|" or "\" within a value is escaped
+// with a backslash.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"oynas/internal/data"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var ErrUnknownFormat = errors.New("format must be csv or jsonl")
+
+// listSeparator joins the values of list fields in CSV, and listEscape
+// escapes it and itself within a value.
+const (
+	listSeparator = '|'
+	listEscape    = '\\'
+)
+
+// columns are the CSV columns, in the order they are exported.
+var columns = []string{
+	"sku", "title", "description", "details", "skills", "categories", "images",
+	"min_age_months", "max_age_months", "manufacturer", "value",
+}
+
+// Record is one toy of a catalog file.
+type Record struct {
+	SKU          string   `json:"sku"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Details      []string `json:"details"`
+	Skills       []string `json:"skills"`
+	Categories   []string `json:"categories"`
+	Images       []string `json:"images"`
+	MinAgeMonths int      `json:"min_age_months"`
+	MaxAgeMonths int      `json:"max_age_months"`
+	Manufacturer string   `json:"manufacturer"`
+	Value        int64    `json:"value"`
+}
+
+func FromToy(toy *data.Toy) Record {
+	record := Record{
+		SKU:          toy.SKU,
+		Title:        toy.Title,
+		Description:  toy.Description,
+		Details:      toy.Details,
+		Skills:       toy.Skills,
+		Categories:   toy.Categories,
+		Images:       toy.Images,
+		MinAgeMonths: toy.RecommendedAge.Min,
+		MaxAgeMonths: toy.RecommendedAge.Max,
+		Value:        toy.Value,
+	}
+
+	if toy.Manufacturer != nil {
+		record.Manufacturer = toy.Manufacturer.Name
+	}
+
+	return record
+}
+
+// Import turns the record into a row for data.ToyModel.Import.
+func (r Record) Import(line int) *data.ToyImport {
+	toy := &data.Toy{
+		SKU:            strings.TrimSpace(r.SKU),
+		Title:          r.Title,
+		Description:    r.Description,
+		Details:        nonNil(r.Details),
+		Skills:         nonNil(r.Skills),
+		Categories:     nonNil(r.Categories),
+		Images:         nonNil(r.Images),
+		RecommendedAge: data.AgeRange{Min: r.MinAgeMonths, Max: r.MaxAgeMonths},
+		Value:          r.Value,
+	}
+
+	return &data.ToyImport{Line: line, Toy: toy, Manufacturer: strings.TrimSpace(r.Manufacturer)}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// RowError is a row of a catalog file that couldn't be parsed. Reading can go
+// on after it.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+type Reader interface {
+	// Read returns the next record and the line it starts on. It returns a
+	// *RowError for a malformed row and io.EOF after the last one.
+	Read() (Record, int, error)
+}
+
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("read csv header: %w", err)
+		}
+
+		index := map[string]int{}
+		for i, column := range header {
+			column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+			if !contains(columns, column) {
+				return nil, fmt.Errorf("unknown csv column %q", column)
+			}
+			index[column] = i
+		}
+
+		return &csvReader{reader: reader, index: index}, nil
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+		return &jsonlReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	index  map[string]int
+}
+
+func (c *csvReader) Read() (Record, int, error) {
+	var record Record
+
+	fields, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return record, parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return record, 0, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+
+	field := func(column string) string {
+		i, ok := c.index[column]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	list := func(column string) []string {
+		return splitList(field(column))
+	}
+
+	number := func(column string) (int64, error) {
+		if field(column) == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(field(column), 10, 64)
+		if err != nil {
+			return 0, &RowError{Line: line, Err: fmt.Errorf("%s must be an integer", column)}
+		}
+		return n, nil
+	}
+
+	record = Record{
+		SKU:          field("sku"),
+		Title:        field("title"),
+		Description:  field("description"),
+		Details:      list("details"),
+		Skills:       list("skills"),
+		Categories:   list("categories"),
+		Images:       list("images"),
+		Manufacturer: field("manufacturer"),
+	}
+
+	minAge, err := number("min_age_months")
+	if err != nil {
+		return record, line, err
+	}
+	maxAge, err := number("max_age_months")
+	if err != nil {
+		return record, line, err
+	}
+	record.Value, err = number("value")
+	if err != nil {
+		return record, line, err
+	}
+
+	record.MinAgeMonths, record.MaxAgeMonths = int(minAge), int(maxAge)
+
+	return record, line, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlReader) Read() (Record, int, error) {
+	var record Record
+
+	for j.scanner.Scan() {
+		j.line++
+
+		text := bytes.TrimSpace(j.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&record)
+		if err != nil {
+			return record, j.line, &RowError{Line: j.line, Err: err}
+		}
+
+		return record, j.line, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return record, j.line, err
+	}
+	return record, j.line, io.EOF
+}
+
+type Writer interface {
+	Write(record Record) error
+	// Flush writes any buffered records and reports the first error of any
+	// Write.
+	Flush() error
+}
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(columns)
+		if err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: writer}, nil
+	case FormatJSONL:
+		buffered := bufio.NewWriter(w)
+		return &jsonlWriter{buffered: buffered, enc: json.NewEncoder(buffered)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvWriter) Write(record Record) error {
+	return c.writer.Write([]string{
+		record.SKU,
+		record.Title,
+		record.Description,
+		joinList(record.Details),
+		joinList(record.Skills),
+		joinList(record.Categories),
+		joinList(record.Images),
+		strconv.Itoa(record.MinAgeMonths),
+		strconv.Itoa(record.MaxAgeMonths),
+		record.Manufacturer,
+		strconv.FormatInt(record.Value, 10),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type jsonlWriter struct {
+	buffered *bufio.Writer
+	enc      *json.Encoder
+}
+
+func (j *jsonlWriter) Write(record Record) error {
+	return j.enc.Encode(record)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.buffered.Flush()
+}
+
+// joinList joins the values of a list field, escaping the separator.
+func joinList(values []string) string {
+	var b strings.Builder
+
+	for i, value := range values {
+		if i > 0 {
+			b.WriteRune(listSeparator)
+		}
+		for _, r := range value {
+			if r == listSeparator || r == listEscape {
+				b.WriteRune(listEscape)
+			}
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// splitList splits a list field joined by joinList. Values are trimmed, and
+// empty ones are left out.
+func splitList(field string) []string {
+	values := []string{}
+
+	var value strings.Builder
+	add := func() {
+		if v := strings.TrimSpace(value.String()); v != "" {
+			values = append(values, v)
+		}
+		value.Reset()
+	}
+
+	escaped := false
+	for _, r := range field {
+		switch {
+		case escaped:
+			value.WriteRune(r)
+			escaped = false
+		case r == listEscape:
+			escaped = true
+		case r == listSeparator:
+			add()
+		default:
+			value.WriteRune(r)
+		}
+	}
+	if escaped {
+		value.WriteRune(listEscape)
+	}
+	add()
+
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
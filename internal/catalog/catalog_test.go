@@ -0,0 +1,217 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll reads every record of the input, with the line each starts on, and
+// the errors of malformed rows.
+func readAll(t *testing.T, input, format string) ([]Record, []int, []error) {
+	t.Helper()
+
+	reader, err := NewReader(strings.NewReader(input), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []Record
+	var lines []int
+	var errs []error
+
+	for {
+		record, line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, lines, errs
+		}
+
+		var rowErr *RowError
+		switch {
+		case errors.As(err, &rowErr):
+			errs = append(errs, err)
+		case err != nil:
+			t.Fatal(err)
+		default:
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+	}
+}
+
+func TestNewReaderCSVHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   Record
+		err    bool
+	}{
+		{"all columns", "sku,title,description,details,skills,categories,images,min_age_months,max_age_months,manufacturer,value", Record{SKU: "A-1", Title: "Train"}, false},
+		{"byte order mark", "\ufeffsku,title", Record{SKU: "A-1", Title: "Train"}, false},
+		{"other order", "title,sku", Record{SKU: "Train", Title: "A-1"}, false},
+		{"spaces around names", " sku , title ", Record{SKU: "A-1", Title: "Train"}, false},
+		{"unknown column", "sku,name", Record{}, true},
+		{"empty", "", Record{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.header
+			if input != "" {
+				input += "\nA-1,Train\n"
+			}
+
+			reader, err := NewReader(strings.NewReader(input), FormatCSV)
+			if tt.err {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := reader.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.SKU != tt.want.SKU || got.Title != tt.want.Title {
+				t.Errorf("got sku %q and title %q; want %q and %q", got.SKU, got.Title, tt.want.SKU, tt.want.Title)
+			}
+		})
+	}
+}
+
+func TestCSVReaderLines(t *testing.T) {
+	input := "sku,title,value\n" +
+		"A-1,Train,5000\n" +
+		"A-2,\"Two\nlines\",6000\n" +
+		"A-3,Drum,lots\n" +
+		"A-4,\"Unclosed,7000\n"
+
+	records, lines, errs := readAll(t, input, FormatCSV)
+
+	if want := []int{2, 3}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %v; want %v", lines, want)
+	}
+	if len(records) == 2 && records[1].Title != "Two\nlines" {
+		t.Errorf("got title %q; want %q", records[1].Title, "Two\nlines")
+	}
+
+	wantErrs := []int{5, 6}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("got errors %v; want them on lines %v", errs, wantErrs)
+	}
+	for i, err := range errs {
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) || rowErr.Line != wantErrs[i] {
+			t.Errorf("got error %v; want it on line %d", err, wantErrs[i])
+		}
+	}
+}
+
+func TestJSONLReaderSkipsBlankLines(t *testing.T) {
+	input := "\n" +
+		`{"sku":"A-1","title":"Train"}` + "\n" +
+		"   \n" +
+		"\n" +
+		`{"sku":"A-2","title":"Drum"}` + "\n" +
+		`{"sku":"A-3","colour":"red"}` + "\n"
+
+	records, lines, errs := readAll(t, input, FormatJSONL)
+
+	if want := []int{2, 5}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %v; want %v", lines, want)
+	}
+	if len(records) != 2 || records[0].SKU != "A-1" || records[1].SKU != "A-2" {
+		t.Errorf("got records %+v; want A-1 and A-2", records)
+	}
+
+	var rowErr *RowError
+	if len(errs) != 1 || !errors.As(errs[0], &rowErr) || rowErr.Line != 6 {
+		t.Errorf("got errors %v; want one on line 6", errs)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := []Record{
+		{
+			SKU:          "A-1",
+			Title:        "Wooden train, \"deluxe\"",
+			Description:  "A train.\nWith carriages.",
+			Details:      []string{"3 carriages", "rails | bridges", `C:\toys`},
+			Skills:       []string{"motor"},
+			Categories:   []string{"vehicles", "wooden"},
+			Images:       []string{"https://example.com/a.jpg"},
+			MinAgeMonths: 24,
+			MaxAgeMonths: 59,
+			Manufacturer: "Toy Co",
+			Value:        15000,
+		},
+		{
+			SKU:          "A-2",
+			Title:        "Drum",
+			Details:      []string{},
+			Skills:       []string{"music"},
+			Categories:   []string{"music"},
+			Images:       []string{},
+			MinAgeMonths: 36,
+			Value:        8000,
+		},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			writer, err := NewWriter(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, record := range records {
+				if err := writer.Write(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, errs := readAll(t, buf.String(), format)
+			if len(errs) != 0 {
+				t.Fatalf("got errors %v", errs)
+			}
+
+			if !reflect.DeepEqual(got, records) {
+				t.Errorf("got %+v; want %+v", got, records)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{"", []string{}},
+		{"a|b", []string{"a", "b"}},
+		{" a | | b ", []string{"a", "b"}},
+		{`a\|b|c`, []string{"a|b", "c"}},
+		{`a\\|b`, []string{`a\`, "b"}},
+		{`a\`, []string{`a\`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if got := splitList(tt.field); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
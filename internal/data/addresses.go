@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+var ErrAddressInUse = errors.New("address has deliveries")
+
+type Address struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	UserID     int64     `json:"user_id"`
+	Label      string    `json:"label,omitempty"`
+	Line1      string    `json:"line1"`
+	Line2      string    `json:"line2,omitempty"`
+	City       string    `json:"city"`
+	PostalCode string    `json:"postal_code,omitempty"`
+	Phone      string    `json:"phone"`
+	Notes      string    `json:"notes,omitempty"`
+	Version    int       `json:"version"`
+}
+
+func ValidateAddress(v *validator.Validator, address *Address) {
+	v.Check(len(address.Label) <= 100, "label", "must not be more than 100 bytes long")
+	v.Check(address.Line1 != "", "line1", "must be provided")
+	v.Check(len(address.Line1) <= 200, "line1", "must not be more than 200 bytes long")
+	v.Check(len(address.Line2) <= 200, "line2", "must not be more than 200 bytes long")
+	v.Check(address.City != "", "city", "must be provided")
+	v.Check(len(address.City) <= 100, "city", "must not be more than 100 bytes long")
+	v.Check(len(address.PostalCode) <= 20, "postal_code", "must not be more than 20 bytes long")
+	v.Check(address.Phone != "", "phone", "must be provided")
+	v.Check(len(address.Phone) <= 30, "phone", "must not be more than 30 bytes long")
+	v.Check(len(address.Notes) <= 500, "notes", "must not be more than 500 bytes long")
+}
+
+type AddressModel struct {
+	DB *sql.DB
+}
+
+func (a AddressModel) Insert(address *Address) error {
+	query := `
+INSERT INTO addresses (user_id, label, line1, line2, city, postal_code, phone, notes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, version`
+
+	args := []any{address.UserID, address.Label, address.Line1, address.Line2, address.City, address.PostalCode, address.Phone, address.Notes}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return a.DB.QueryRowContext(ctx, query, args...).Scan(&address.ID, &address.CreatedAt, &address.Version)
+}
+
+// GetForUser returns one of the user's addresses. Addresses of other users
+// are reported as not found.
+func (a AddressModel) GetForUser(id, userID int64) (*Address, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, user_id, label, line1, line2, city, postal_code, phone, notes, version
+FROM addresses
+WHERE id = $1 AND user_id = $2`
+
+	var address Address
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := a.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&address.ID,
+		&address.CreatedAt,
+		&address.UserID,
+		&address.Label,
+		&address.Line1,
+		&address.Line2,
+		&address.City,
+		&address.PostalCode,
+		&address.Phone,
+		&address.Notes,
+		&address.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &address, nil
+}
+
+func (a AddressModel) GetAllForUser(userID int64) ([]*Address, error) {
+	query := `
+SELECT id, created_at, user_id, label, line1, line2, city, postal_code, phone, notes, version
+FROM addresses
+WHERE user_id = $1
+ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := a.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []*Address{}
+
+	for rows.Next() {
+		var address Address
+
+		err := rows.Scan(
+			&address.ID,
+			&address.CreatedAt,
+			&address.UserID,
+			&address.Label,
+			&address.Line1,
+			&address.Line2,
+			&address.City,
+			&address.PostalCode,
+			&address.Phone,
+			&address.Notes,
+			&address.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, &address)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+func (a AddressModel) Update(address *Address) error {
+	query := `
+UPDATE addresses
+SET label = $1, line1 = $2, line2 = $3, city = $4, postal_code = $5, phone = $6, notes = $7, version = version + 1
+WHERE id = $8 AND user_id = $9 AND version = $10
+RETURNING version`
+
+	args := []any{
+		address.Label,
+		address.Line1,
+		address.Line2,
+		address.City,
+		address.PostalCode,
+		address.Phone,
+		address.Notes,
+		address.ID,
+		address.UserID,
+		address.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := a.DB.QueryRowContext(ctx, query, args...).Scan(&address.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes one of the user's addresses. Addresses that deliveries were
+// booked to are kept so that the courier history stays intact.
+func (a AddressModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := a.DB.ExecContext(ctx, `DELETE FROM addresses WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Constraint == "deliveries_address_id_fkey" {
+			return ErrAddressInUse
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
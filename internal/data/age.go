@@ -0,0 +1,102 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AgeRange is the age a toy is meant for, in months. Max is inclusive, so a
+// toy for 2-4 year olds ends at 59 months, the last month a child is 4. A zero
+// Max means the range has no upper bound.
+type AgeRange struct {
+	Min int
+	Max int
+}
+
+var ErrInvalidAgeRangeFormat = errors.New(`invalid age range format, expected something like "3+", "2-4 years" or "6-18 months"`)
+
+var ageRangeRX = regexp.MustCompile(`^(\d+)\s*(?:(\+)|-\s*(\d+))?\s*(years?|months?)?$`)
+
+// ParseAgeRange reads an age range such as "3+", "2-4 years" or "6-18 months".
+// Ages are in years unless months are given.
+func ParseAgeRange(s string) (AgeRange, error) {
+	matches := ageRangeRX.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if matches == nil {
+		return AgeRange{}, ErrInvalidAgeRangeFormat
+	}
+
+	unit := 12
+	if strings.HasPrefix(matches[4], "month") {
+		unit = 1
+	}
+
+	min, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return AgeRange{}, ErrInvalidAgeRangeFormat
+	}
+
+	ageRange := AgeRange{Min: min * unit}
+
+	switch {
+	case matches[3] != "":
+		max, err := strconv.Atoi(matches[3])
+		if err != nil {
+			return AgeRange{}, ErrInvalidAgeRangeFormat
+		}
+		ageRange.Max = (max+1)*unit - 1
+	case matches[2] == "":
+		// A single age, such as "3 years".
+		ageRange.Max = (min+1)*unit - 1
+	}
+
+	// A zero Max is how an unbounded range is stored, so a bounded range
+	// can't end in the first month; "0 months" would read back as "0+".
+	if matches[2] == "" && ageRange.Max == 0 {
+		return AgeRange{}, ErrInvalidAgeRangeFormat
+	}
+
+	return ageRange, nil
+}
+
+// Contains reports whether a child of the given age in months is in range.
+func (a AgeRange) Contains(months int) bool {
+	return months >= a.Min && (a.Max == 0 || months <= a.Max)
+}
+
+func (a AgeRange) String() string {
+	unit, min, max := "months", a.Min, a.Max
+	if a.Min%12 == 0 && (a.Max == 0 || (a.Max+1)%12 == 0) {
+		unit, min, max = "years", a.Min/12, (a.Max+1)/12-1
+	}
+
+	switch {
+	case a.Max == 0:
+		return fmt.Sprintf("%d+ %s", min, unit)
+	case min == max:
+		return fmt.Sprintf("%d %s", min, unit)
+	default:
+		return fmt.Sprintf("%d-%d %s", min, max, unit)
+	}
+}
+
+func (a AgeRange) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+func (a *AgeRange) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidAgeRangeFormat
+	}
+
+	ageRange, err := ParseAgeRange(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+
+	*a = ageRange
+	return nil
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAgeRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  AgeRange
+		err   error
+	}{
+		{"3+", AgeRange{Min: 36}, nil},
+		{"3+ years", AgeRange{Min: 36}, nil},
+		{"6+ months", AgeRange{Min: 6}, nil},
+		{"2-4", AgeRange{Min: 24, Max: 59}, nil},
+		{"2-4 years", AgeRange{Min: 24, Max: 59}, nil},
+		{" 2 - 4 Years ", AgeRange{Min: 24, Max: 59}, nil},
+		{"0-1 year", AgeRange{Min: 0, Max: 23}, nil},
+		{"6-18 months", AgeRange{Min: 6, Max: 18}, nil},
+		{"3", AgeRange{Min: 36, Max: 47}, nil},
+		{"3 years", AgeRange{Min: 36, Max: 47}, nil},
+		{"9 months", AgeRange{Min: 9, Max: 9}, nil},
+		{"", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"three", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"2-4 weeks", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"-4", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"2-", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"0 months", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"0-0 months", AgeRange{}, ErrInvalidAgeRangeFormat},
+		{"0-1 months", AgeRange{Min: 0, Max: 1}, nil},
+		{"0+ months", AgeRange{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAgeRange(tt.input)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v; want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgeRangeContains(t *testing.T) {
+	tests := []struct {
+		name   string
+		age    AgeRange
+		months int
+		want   bool
+	}{
+		{"below minimum", AgeRange{Min: 24, Max: 59}, 23, false},
+		{"at minimum", AgeRange{Min: 24, Max: 59}, 24, true},
+		{"last month of maximum year", AgeRange{Min: 24, Max: 59}, 59, true},
+		{"above maximum", AgeRange{Min: 24, Max: 59}, 60, false},
+		{"no upper bound", AgeRange{Min: 36}, 200, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.age.Contains(tt.months); got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgeRangeString(t *testing.T) {
+	tests := []string{"3+ years", "6+ months", "2-4 years", "3 years", "6-18 months", "9 months"}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			age, err := ParseAgeRange(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := age.String(); got != input {
+				t.Errorf("got %q; want %q", got, input)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+var (
+	ErrAlreadyInBucket = errors.New("toy already in bucket")
+	ErrEmptyBucket     = errors.New("bucket is empty")
+)
+
+type BucketModel struct {
+	DB *sql.DB
+}
+
+func (b BucketModel) GetToys(userID int64) ([]*Toy, error) {
+	query := `SELECT ` + toyDetailColumns + `
+FROM users
+INNER JOIN toys ON toys.id = ANY(users.bucket)
+WHERE users.id = $1
+ORDER BY array_position(users.bucket, toys.id)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := b.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toys := []*Toy{}
+
+	for rows.Next() {
+		toy, err := scanToyDetails(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		toys = append(toys, toy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toys, nil
+}
+
+// Add puts a toy in the user's bucket. Toys in the bucket count against the
+// user's plan together with the toys they already have on loan. The user row
+// is locked for the duration of the transaction so the limit can't be raced.
+func (b BucketModel) Add(userID, toyID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bucket []int64
+
+	err = tx.QueryRowContext(ctx, `SELECT bucket FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(pq.Array(&bucket))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range bucket {
+		if id == toyID {
+			return ErrAlreadyInBucket
+		}
+	}
+
+	var inStock, heldForOthers int
+
+	query := `
+SELECT
+	(SELECT count(*) FROM toy_units WHERE toy_units.toy_id = toys.id AND toy_units.status = 'in_stock'),
+	(SELECT count(*) FROM wait_list WHERE wait_list.toy_id = toys.id AND wait_list.user_id <> $2
+		AND wait_list.status = 'offered' AND wait_list.hold_expiry > now())
+FROM toys
+WHERE id = $1 AND archived_at IS NULL`
+
+	err = tx.QueryRowContext(ctx, query, toyID, userID).Scan(&inStock, &heldForOthers)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case inStock == 0:
+		return ErrToyUnavailable
+	case heldForOthers >= inStock:
+		return ErrToyOnHold
+	}
+
+	err = checkPlanLimits(ctx, tx, userID, append(bucket, toyID))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET bucket = array_append(bucket, $2) WHERE id = $1`, userID, toyID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (b BucketModel) Remove(userID, toyID int64) error {
+	query := `
+UPDATE users
+SET bucket = array_remove(bucket, $2)
+WHERE id = $1 AND $2 = ANY(bucket)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := b.DB.ExecContext(ctx, query, userID, toyID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Submit turns the contents of the user's bucket into a pending checkout
+// request and empties the bucket.
+func (b BucketModel) Submit(userID int64) (*CheckoutRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	checkout := &CheckoutRequest{UserID: userID}
+
+	query := `
+INSERT INTO checkout_requests (user_id, toy_ids)
+SELECT id, bucket FROM users
+WHERE id = $1 AND cardinality(bucket) > 0
+FOR UPDATE
+RETURNING id, created_at, toy_ids, status, version`
+
+	err = tx.QueryRowContext(ctx, query, userID).Scan(
+		&checkout.ID,
+		&checkout.CreatedAt,
+		pq.Array(&checkout.ToyIDs),
+		&checkout.Status,
+		&checkout.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrEmptyBucket
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET bucket = '{}' WHERE id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return checkout, nil
+}
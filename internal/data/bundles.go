@@ -0,0 +1,341 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+var (
+	ErrUnknownToy  = errors.New("unknown toy")
+	ErrBundleEmpty = errors.New("bundle has no toys")
+)
+
+// Bundle is a themed kit of toys that are rented together. Its value is the
+// combined value of its toys, and it is available only when all of them are.
+type Bundle struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	Title       string    `json:"title"`
+	Description string    `json:"desc"`
+	Images      []string  `json:"image"`
+	ToyIDs      []int64   `json:"toy_ids"`
+	Toys        []*Toy    `json:"toys,omitempty"`
+	Value       int64     `json:"value"`
+	IsAvailable bool      `json:"isAvailable"`
+	Version     int       `json:"version,omitempty"`
+}
+
+func ValidateBundle(v *validator.Validator, bundle *Bundle) {
+	v.Check(bundle.Title != "", "title", "title must be provided")
+	v.Check(len(bundle.Title) <= 500, "title", "title must not be more than 500 bytes long")
+	v.Check(len(bundle.Description) <= 5000, "desc", "Description must not be more than 5000 bytes long")
+	v.Check(v.ImageUrlsCheck(bundle.Images), "image", "some of image urls is wrong")
+	v.Check(len(bundle.Images) <= 20, "image", "images must not be more than 20")
+	v.Check(len(bundle.ToyIDs) >= 2, "toy_ids", "at least 2 toys")
+	v.Check(len(bundle.ToyIDs) <= 20, "toy_ids", "no more than 20 toys")
+	v.Check(validator.Unique(bundle.ToyIDs), "toy_ids", "toys should not contain duplicate values")
+}
+
+type BundleModel struct {
+	DB *sql.DB
+}
+
+// bundleColumns is the column list read by scanBundle.
+const bundleColumns = `bundles.id, bundles.created_at, bundles.title, bundles.description, bundles.images,
+	ARRAY(SELECT toy_id FROM bundle_toys WHERE bundle_toys.bundle_id = bundles.id ORDER BY position),
+	(SELECT COALESCE(sum(toys.value), 0) FROM bundle_toys INNER JOIN toys ON toys.id = bundle_toys.toy_id
+		WHERE bundle_toys.bundle_id = bundles.id) AS value,
+	EXISTS (SELECT 1 FROM bundle_toys WHERE bundle_toys.bundle_id = bundles.id)
+		AND NOT EXISTS (SELECT 1 FROM bundle_toys INNER JOIN toys ON toys.id = bundle_toys.toy_id
+		WHERE bundle_toys.bundle_id = bundles.id AND (toys.archived_at IS NOT NULL OR ` + toyUnitsAvailable + ` = 0)) AS is_available,
+	bundles.version`
+
+// scanBundle reads a row of bundleColumns, after the columns read into dest.
+func scanBundle(row rowScanner, dest ...any) (*Bundle, error) {
+	var bundle Bundle
+
+	dest = append(dest,
+		&bundle.ID,
+		&bundle.CreatedAt,
+		&bundle.Title,
+		&bundle.Description,
+		pq.Array(&bundle.Images),
+		pq.Array(&bundle.ToyIDs),
+		&bundle.Value,
+		&bundle.IsAvailable,
+		&bundle.Version,
+	)
+
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundle, nil
+}
+
+// Insert adds a bundle of toys that exist and aren't archived.
+func (b BundleModel) Insert(bundle *Bundle) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+INSERT INTO bundles (title, description, images)
+VALUES ($1, $2, $3)
+RETURNING id`
+
+	err = tx.QueryRowContext(ctx, query, bundle.Title, bundle.Description, pq.Array(bundle.Images)).Scan(&bundle.ID)
+	if err != nil {
+		return err
+	}
+
+	err = saveBundle(ctx, tx, bundle)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (b BundleModel) Get(id int64) (*Bundle, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + bundleColumns + `
+FROM bundles
+WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	bundle, err := scanBundle(b.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return bundle, nil
+}
+
+// Update saves a bundle if its version is still the one it was read with, and
+// returns ErrEditConflict otherwise.
+func (b BundleModel) Update(bundle *Bundle) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+UPDATE bundles
+SET title = $1, description = $2, images = $3, version = version + 1
+WHERE id = $4 AND version = $5
+RETURNING id`
+
+	args := []any{bundle.Title, bundle.Description, pq.Array(bundle.Images), bundle.ID, bundle.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&bundle.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = saveBundle(ctx, tx, bundle)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveBundle replaces the toys of a bundle inside an existing transaction and
+// reads the bundle back with its value, availability and version.
+func saveBundle(ctx context.Context, tx *sql.Tx, bundle *Bundle) error {
+	var found int
+	err := tx.QueryRowContext(ctx, `SELECT count(*) FROM toys WHERE id = ANY($1) AND archived_at IS NULL`, pq.Array(bundle.ToyIDs)).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != len(bundle.ToyIDs) {
+		return ErrUnknownToy
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM bundle_toys WHERE bundle_id = $1`, bundle.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+INSERT INTO bundle_toys (bundle_id, toy_id, position)
+SELECT $1, toy.id, toy.position
+FROM unnest($2::bigint[]) WITH ORDINALITY AS toy (id, position)`
+
+	_, err = tx.ExecContext(ctx, query, bundle.ID, pq.Array(bundle.ToyIDs))
+	if err != nil {
+		return err
+	}
+
+	saved, err := scanBundle(tx.QueryRowContext(ctx, `SELECT `+bundleColumns+` FROM bundles WHERE id = $1`, bundle.ID))
+	if err != nil {
+		return err
+	}
+
+	*bundle = *saved
+	return nil
+}
+
+func (b BundleModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := b.DB.ExecContext(ctx, `DELETE FROM bundles WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll lists the bundles whose title contains the given one. Only bundles
+// whose toys are all available are listed when availableOnly is set.
+func (b BundleModel) GetAll(title string, availableOnly bool, filters Filters) ([]*Bundle, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), *
+FROM (
+	SELECT `+bundleColumns+`
+	FROM bundles
+	WHERE (title ILIKE '%%' || $1 || '%%' OR $1 = '')
+) AS bundles
+WHERE (is_available OR NOT $2)
+ORDER BY %s %s, id ASC
+LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := b.DB.QueryContext(ctx, query, likeEscaper.Replace(title), availableOnly, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	bundles := []*Bundle{}
+
+	for rows.Next() {
+		bundle, err := scanBundle(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		bundles = append(bundles, bundle)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return bundles, metadata, nil
+}
+
+// Checkout lends every toy of a bundle to the user. Either all loans are
+// created or, if any toy can't be checked out, none are. The toys are locked
+// in id order, so concurrent checkouts of overlapping bundles can't deadlock.
+func (b BundleModel) Checkout(bundleID, userID, childID int64, dueDate time.Time) ([]*Loan, error) {
+	if bundleID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The bundle is locked so that its toys can't change during the checkout.
+	var toyIDs []int64
+	query := `
+SELECT ARRAY(SELECT toy_id FROM bundle_toys WHERE bundle_toys.bundle_id = bundles.id ORDER BY toy_id)
+FROM bundles
+WHERE id = $1
+FOR SHARE`
+
+	err = tx.QueryRowContext(ctx, query, bundleID).Scan(pq.Array(&toyIDs))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if len(toyIDs) == 0 {
+		return nil, ErrBundleEmpty
+	}
+
+	loans := []*Loan{}
+
+	for _, toyID := range toyIDs {
+		loan := &Loan{
+			ToyID:   toyID,
+			UserID:  userID,
+			ChildID: childID,
+			DueDate: dueDate,
+		}
+
+		err = insertLoan(ctx, tx, loan)
+		if err != nil {
+			return nil, &ToyCheckoutError{ToyID: toyID, Err: err}
+		}
+
+		loans = append(loans, loan)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
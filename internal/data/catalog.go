@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+// catalogTimeout bounds a whole import batch or export, which take far longer
+// than a single request's query.
+const catalogTimeout = 5 * time.Minute
+
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportInvalid = "invalid"
+)
+
+// ToyImport is one row of a catalog import. Toy is identified by its SKU, and
+// its manufacturer is given by name and created when it doesn't exist yet.
+// Import fills in Action and, for invalid rows, Errors.
+type ToyImport struct {
+	Line         int               `json:"line"`
+	Toy          *Toy              `json:"-"`
+	Manufacturer string            `json:"-"`
+	SKU          string            `json:"sku"`
+	Action       string            `json:"action"`
+	Errors       map[string]string `json:"errors,omitempty"`
+}
+
+// Import upserts a batch of toys by SKU in one transaction. Rows that fail
+// validation or are rejected by the database are reported in their Errors and
+// skipped, without failing the rest of the batch. With dryRun, the batch is
+// rolled back once every row has been checked.
+func (t ToyModel) Import(rows []*ToyImport, taxonomy *Taxonomy, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), catalogTimeout)
+	defer cancel()
+
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		row.SKU = row.Toy.SKU
+
+		// Each row gets a savepoint, so that a row the database rejects
+		// doesn't abort the transaction for the rows after it.
+		_, err = tx.ExecContext(ctx, `SAVEPOINT toy_import`)
+		if err != nil {
+			return err
+		}
+
+		err = importToy(ctx, tx, row, taxonomy)
+		if err != nil {
+			return err
+		}
+
+		if row.Action == ImportInvalid {
+			_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT toy_import`)
+		} else {
+			_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT toy_import`)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+// importToy saves one row of an import. Rows that are invalid or rejected by
+// the database are marked as such, and only other errors are returned.
+func importToy(ctx context.Context, tx *sql.Tx, row *ToyImport, taxonomy *Taxonomy) error {
+	var err error
+
+	row.Toy.ManufacturerID, err = resolveManufacturer(ctx, tx, row.Manufacturer)
+	if err == nil {
+		v := validator.New()
+
+		v.Check(row.Toy.SKU != "", "sku", "sku must be provided")
+		if ValidateToy(v, row.Toy, taxonomy); !v.Valid() {
+			row.Action, row.Errors = ImportInvalid, v.Errors
+			return nil
+		}
+
+		row.Action, err = upsertToy(ctx, tx, row.Toy)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			return err
+		}
+
+		row.Action, row.Errors = ImportInvalid, map[string]string{"row": pqErr.Message}
+	}
+
+	return nil
+}
+
+func upsertToy(ctx context.Context, tx *sql.Tx, toy *Toy) (string, error) {
+	query := `
+INSERT INTO toys (sku, title, description, details, skills, categories, images, min_age_months, max_age_months, manufacturer_id, value)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, 0), $10, $11)
+ON CONFLICT (sku) DO UPDATE
+SET title = EXCLUDED.title, description = EXCLUDED.description, details = EXCLUDED.details, skills = EXCLUDED.skills,
+	categories = EXCLUDED.categories, images = EXCLUDED.images, min_age_months = EXCLUDED.min_age_months,
+	max_age_months = EXCLUDED.max_age_months, manufacturer_id = EXCLUDED.manufacturer_id, value = EXCLUDED.value,
+	version = toys.version + 1
+RETURNING id, created_at, manufacturer, version, xmax = 0`
+
+	args := []any{
+		toy.SKU,
+		toy.Title,
+		toy.Description,
+		pq.Array(toy.Details),
+		pq.Array(toy.Skills),
+		pq.Array(toy.Categories),
+		pq.Array(toy.Images),
+		toy.RecommendedAge.Min,
+		toy.RecommendedAge.Max,
+		toy.ManufacturerID,
+		toy.Value,
+	}
+
+	var manufacturerName string
+	var inserted bool
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&toy.ID, &toy.CreatedAt, &manufacturerName, &toy.Version, &inserted)
+	if err != nil {
+		return "", err
+	}
+
+	toy.setManufacturer(manufacturerName)
+
+	if inserted {
+		return ImportCreated, nil
+	}
+	return ImportUpdated, nil
+}
+
+// resolveManufacturer returns the id of the manufacturer with the given name,
+// matched the way manufacturers are deduplicated, and adds it if there is
+// none. An empty name resolves to 0.
+func resolveManufacturer(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO manufacturers (name)
+VALUES (btrim($1))
+ON CONFLICT (manufacturer_key(name)) DO NOTHING`, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+SELECT id FROM manufacturers
+WHERE manufacturer_key(name) = manufacturer_key($1)`, name).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Export calls fn with every toy of the catalog that isn't archived, by id.
+func (t ToyModel) Export(fn func(toy *Toy) error) error {
+	query := `
+SELECT id, created_at, COALESCE(sku, ''), title, description, details, skills, categories, images,
+	min_age_months, COALESCE(max_age_months, 0), COALESCE(manufacturer_id, 0), COALESCE(manufacturer, ''), value
+FROM toys
+WHERE archived_at IS NULL
+ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), catalogTimeout)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var toy Toy
+		var manufacturerName string
+
+		err := rows.Scan(
+			&toy.ID,
+			&toy.CreatedAt,
+			&toy.SKU,
+			&toy.Title,
+			&toy.Description,
+			pq.Array(&toy.Details),
+			pq.Array(&toy.Skills),
+			pq.Array(&toy.Categories),
+			pq.Array(&toy.Images),
+			&toy.RecommendedAge.Min,
+			&toy.RecommendedAge.Max,
+			&toy.ManufacturerID,
+			&manufacturerName,
+			&toy.Value,
+		)
+		if err != nil {
+			return err
+		}
+
+		toy.setManufacturer(manufacturerName)
+
+		err = fn(&toy)
+		if err != nil {
+			return fmt.Errorf("export toy %d: %w", toy.ID, err)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+const (
+	CheckoutPending  = "pending"
+	CheckoutApproved = "approved"
+	CheckoutRejected = "rejected"
+)
+
+var ErrCheckoutProcessed = errors.New("checkout request already processed")
+
+// ToyCheckoutError is returned when one of several toys checked out together
+// can't be lent. It names the toy and wraps the reason.
+type ToyCheckoutError struct {
+	ToyID int64
+	Err   error
+}
+
+func (e *ToyCheckoutError) Error() string {
+	return fmt.Sprintf("toy %d: %v", e.ToyID, e.Err)
+}
+
+func (e *ToyCheckoutError) Unwrap() error {
+	return e.Err
+}
+
+type CheckoutRequest struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"user_id"`
+	ToyIDs    []int64   `json:"toy_ids"`
+	Status    string    `json:"status"`
+	Version   int       `json:"-"`
+}
+
+func ValidateCheckoutStatus(v *validator.Validator, status string) {
+	v.Check(validator.PermittedValue(status, CheckoutPending, CheckoutApproved, CheckoutRejected), "status", "must be pending, approved or rejected")
+}
+
+type CheckoutModel struct {
+	DB *sql.DB
+}
+
+func (c CheckoutModel) Get(id int64) (*CheckoutRequest, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, user_id, toy_ids, status, version
+FROM checkout_requests
+WHERE id = $1`
+
+	var checkout CheckoutRequest
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+		&checkout.ID,
+		&checkout.CreatedAt,
+		&checkout.UserID,
+		pq.Array(&checkout.ToyIDs),
+		&checkout.Status,
+		&checkout.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &checkout, nil
+}
+
+// GetAll lists checkout requests with the given status. A zero userID lists
+// the requests of every user.
+func (c CheckoutModel) GetAll(userID int64, status string, filters Filters) ([]*CheckoutRequest, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, user_id, toy_ids, status, version
+FROM checkout_requests
+WHERE (user_id = $1 OR $1 = 0)
+AND status = $2
+ORDER BY %s %s, id ASC
+LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, userID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	checkouts := []*CheckoutRequest{}
+
+	for rows.Next() {
+		var checkout CheckoutRequest
+
+		err := rows.Scan(
+			&totalRecords,
+			&checkout.ID,
+			&checkout.CreatedAt,
+			&checkout.UserID,
+			pq.Array(&checkout.ToyIDs),
+			&checkout.Status,
+			&checkout.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		checkouts = append(checkouts, &checkout)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return checkouts, metadata, nil
+}
+
+// Approve checks out every toy of a pending request to its user. Either all
+// loans are created or, if any toy can't be checked out, none are.
+func (c CheckoutModel) Approve(checkout *CheckoutRequest, dueDate time.Time) ([]*Loan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = setCheckoutStatus(ctx, tx, checkout, CheckoutApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	loans := []*Loan{}
+
+	for _, toyID := range checkout.ToyIDs {
+		loan := &Loan{
+			ToyID:   toyID,
+			UserID:  checkout.UserID,
+			DueDate: dueDate,
+		}
+
+		err = insertLoan(ctx, tx, loan)
+		if err != nil {
+			return nil, &ToyCheckoutError{ToyID: toyID, Err: err}
+		}
+
+		loans = append(loans, loan)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
+}
+
+func (c CheckoutModel) Reject(checkout *CheckoutRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setCheckoutStatus(ctx, tx, checkout, CheckoutRejected)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setCheckoutStatus(ctx context.Context, tx *sql.Tx, checkout *CheckoutRequest, status string) error {
+	if checkout.Status != CheckoutPending {
+		return ErrCheckoutProcessed
+	}
+
+	query := `
+UPDATE checkout_requests
+SET status = $1, version = version + 1
+WHERE id = $2 AND version = $3 AND status = 'pending'
+RETURNING status, version`
+
+	err := tx.QueryRowContext(ctx, query, status, checkout.ID, checkout.Version).Scan(&checkout.Status, &checkout.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
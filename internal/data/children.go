@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+var ErrUnknownChild = errors.New("unknown child")
+
+type Child struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	BirthDate Date      `json:"birth_date"`
+	Interests []string  `json:"interests"`
+	Version   int       `json:"version"`
+}
+
+// AgeInMonths is the number of full months the child is old at the given time.
+func (c *Child) AgeInMonths(at time.Time) int {
+	months := (at.Year()-c.BirthDate.Year())*12 + int(at.Month()-c.BirthDate.Month())
+	if at.Day() < c.BirthDate.Day() {
+		months--
+	}
+	if months < 0 {
+		return 0
+	}
+	return months
+}
+
+func ValidateChild(v *validator.Validator, child *Child) {
+	v.Check(child.Name != "", "name", "must be provided")
+	v.Check(len(child.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(!child.BirthDate.IsZero(), "birth_date", "must be provided")
+	v.Check(child.BirthDate.Before(time.Now()), "birth_date", "must be in the past")
+	v.Check(child.BirthDate.After(time.Now().AddDate(-18, 0, 0)), "birth_date", "must not be more than 18 years ago")
+	v.Check(child.Interests != nil, "interests", "must be provided")
+	v.Check(len(child.Interests) <= 10, "interests", "must not contain more than 10 interests")
+	v.Check(validator.Unique(child.Interests), "interests", "must not contain duplicate values")
+
+	for _, interest := range child.Interests {
+		v.Check(interest != "", "interests", "must not contain empty values")
+		v.Check(len(interest) <= 50, "interests", "must not contain values longer than 50 bytes")
+	}
+}
+
+type ChildModel struct {
+	DB *sql.DB
+}
+
+func (c ChildModel) Insert(child *Child) error {
+	query := `
+INSERT INTO children (user_id, name, birth_date, interests)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at, version`
+
+	args := []any{child.UserID, child.Name, child.BirthDate.Time, pq.Array(child.Interests)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return c.DB.QueryRowContext(ctx, query, args...).Scan(&child.ID, &child.CreatedAt, &child.Version)
+}
+
+// GetForUser returns one of the user's children. Children of other users are
+// reported as not found.
+func (c ChildModel) GetForUser(id, userID int64) (*Child, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, user_id, name, birth_date, interests, version
+FROM children
+WHERE id = $1 AND user_id = $2`
+
+	var child Child
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&child.ID,
+		&child.CreatedAt,
+		&child.UserID,
+		&child.Name,
+		&child.BirthDate.Time,
+		pq.Array(&child.Interests),
+		&child.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &child, nil
+}
+
+func (c ChildModel) GetAllForUser(userID int64) ([]*Child, error) {
+	query := `
+SELECT id, created_at, user_id, name, birth_date, interests, version
+FROM children
+WHERE user_id = $1
+ORDER BY birth_date ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	children := []*Child{}
+
+	for rows.Next() {
+		var child Child
+
+		err := rows.Scan(
+			&child.ID,
+			&child.CreatedAt,
+			&child.UserID,
+			&child.Name,
+			&child.BirthDate.Time,
+			pq.Array(&child.Interests),
+			&child.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		children = append(children, &child)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return children, nil
+}
+
+func (c ChildModel) Update(child *Child) error {
+	query := `
+UPDATE children
+SET name = $1, birth_date = $2, interests = $3, version = version + 1
+WHERE id = $4 AND user_id = $5 AND version = $6
+RETURNING version`
+
+	args := []any{child.Name, child.BirthDate.Time, pq.Array(child.Interests), child.ID, child.UserID, child.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&child.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (c ChildModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, `DELETE FROM children WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
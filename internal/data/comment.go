@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"oynas/internal/validator"
 	"time"
 )
@@ -20,6 +21,7 @@ type Comment struct {
 func ValidateComment(v *validator.Validator, comment *Comment) {
 	v.Check(comment.Text != "", "text", "text must be provided")
 	v.Check(len(comment.Text) <= 1000, "text", "text must not be bigger than 1000 bytes")
+	v.Check(comment.Rating >= 1 && int(comment.Rating) <= maxRating, "rating", fmt.Sprintf("rating must be from 1 to %d", maxRating))
 }
 
 var (
@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+var (
+	ErrLoanNotReturned  = errors.New("loan not returned")
+	ErrAlreadyInspected = errors.New("loan already inspected")
+)
+
+type DamageReport struct {
+	ID           int64        `json:"id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	LoanID       int64        `json:"loan_id"`
+	ToyID        int64        `json:"toy_id"`
+	UnitID       int64        `json:"unit_id"`
+	RenterID     int64        `json:"renter_id"`
+	InspectorID  int64        `json:"inspector_id,omitempty"`
+	Condition    string       `json:"condition"`
+	MissingParts []string     `json:"missing_parts"`
+	Photos       []string     `json:"photos"`
+	Notes        string       `json:"notes,omitempty"`
+	Fee          int64        `json:"fee"`
+	UnitStatus   string       `json:"unit_status,omitempty"`
+	Charge       *LedgerEntry `json:"charge,omitempty"`
+}
+
+func ValidateDamageReport(v *validator.Validator, report *DamageReport) {
+	v.Check(validator.PermittedValue(report.Condition, UnitConditions...), "condition", "must be new, like_new, good, fair or poor")
+	v.Check(len(report.MissingParts) <= 20, "missing_parts", "must not contain more than 20 parts")
+	v.Check(validator.Unique(report.MissingParts), "missing_parts", "must not contain duplicate values")
+	v.Check(len(report.Photos) <= 10, "photos", "must not contain more than 10 photos")
+	v.Check(v.ImageUrlsCheck(report.Photos), "photos", "some of photo urls is wrong")
+	v.Check(len(report.Notes) <= 2000, "notes", "must not be more than 2000 bytes long")
+	v.Check(report.Fee >= 0, "fee", "must not be negative")
+	v.Check(report.Fee <= 150000, "fee", "must not be more than 150.000 tenge")
+	v.Check(validator.PermittedValue(report.UnitStatus, UnitInStock, UnitCleaning, UnitRetired), "unit_status", "must be in_stock, cleaning or retired")
+}
+
+type DamageReportModel struct {
+	DB *sql.DB
+}
+
+// Insert records the inspection of a returned loan, updates the unit's
+// condition and status and charges the damage fee, if any, to the renter.
+func (d DamageReportModel) Insert(report *DamageReport, loan *Loan) error {
+	if !loan.IsReturned() {
+		return ErrLoanNotReturned
+	}
+
+	report.LoanID = loan.ID
+	report.ToyID = loan.ToyID
+	report.UnitID = loan.UnitID
+	report.RenterID = loan.UserID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var unitStatus string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM toy_units WHERE id = $1 FOR UPDATE`, report.UnitID).Scan(&unitStatus)
+	if err != nil {
+		return err
+	}
+	if unitStatus == UnitRented {
+		return ErrUnitRented
+	}
+
+	// The report is saved before the fee is charged, so that a second
+	// inspection of the loan fails as such instead of reusing the charge's
+	// idempotency key with a different fee.
+	query := `
+INSERT INTO damage_reports (loan_id, toy_id, unit_id, inspector_id, condition, missing_parts, photos, notes, fee)
+VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9)
+ON CONFLICT (loan_id) DO NOTHING
+RETURNING id, created_at`
+
+	args := []any{
+		report.LoanID,
+		report.ToyID,
+		report.UnitID,
+		report.InspectorID,
+		report.Condition,
+		pq.Array(report.MissingParts),
+		pq.Array(report.Photos),
+		report.Notes,
+		report.Fee,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&report.ID, &report.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrAlreadyInspected
+		default:
+			return err
+		}
+	}
+
+	if report.Fee > 0 {
+		report.Charge = &LedgerEntry{
+			UserID:         report.RenterID,
+			Kind:           LedgerCharge,
+			Amount:         -report.Fee,
+			Description:    fmt.Sprintf("damage fee for loan %d", report.LoanID),
+			IdempotencyKey: fmt.Sprintf("damage-report:%d", report.LoanID),
+		}
+
+		_, err = postEntry(ctx, tx, report.Charge, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE damage_reports SET ledger_entry_id = $1 WHERE id = $2`, report.Charge.ID, report.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE toy_units SET condition = $1, status = $2, version = version + 1
+WHERE id = $3`, report.Condition, report.UnitStatus, report.UnitID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d DamageReportModel) GetAllForToy(toyID int64) ([]*DamageReport, error) {
+	query := `
+SELECT damage_reports.id, damage_reports.created_at, damage_reports.loan_id, damage_reports.toy_id, damage_reports.unit_id,
+	loans.user_id, COALESCE(damage_reports.inspector_id, 0), damage_reports.condition, damage_reports.missing_parts,
+	damage_reports.photos, damage_reports.notes, damage_reports.fee
+FROM damage_reports
+INNER JOIN loans ON loans.id = damage_reports.loan_id
+WHERE damage_reports.toy_id = $1
+ORDER BY damage_reports.created_at DESC, damage_reports.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := d.DB.QueryContext(ctx, query, toyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*DamageReport{}
+
+	for rows.Next() {
+		var report DamageReport
+
+		err := rows.Scan(
+			&report.ID,
+			&report.CreatedAt,
+			&report.LoanID,
+			&report.ToyID,
+			&report.UnitID,
+			&report.RenterID,
+			&report.InspectorID,
+			&report.Condition,
+			pq.Array(&report.MissingParts),
+			pq.Array(&report.Photos),
+			&report.Notes,
+			&report.Fee,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar day. It is written to and read from JSON as YYYY-MM-DD.
+type Date struct {
+	time.Time
+}
+
+var ErrInvalidDateFormat = errors.New("invalid date format, expected YYYY-MM-DD")
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.Format(dateLayout))), nil
+}
+
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	t, err := time.Parse(dateLayout, unquotedJSONValue)
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	d.Time = t
+	return nil
+}
@@ -0,0 +1,555 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+const (
+	DeliveryDropOff = "delivery"
+	DeliveryPickup  = "pickup"
+)
+
+const (
+	DeliveryScheduled = "scheduled"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryCancelled = "cancelled"
+)
+
+var (
+	ErrUnknownSlot       = errors.New("unknown delivery slot")
+	ErrUnknownAddress    = errors.New("unknown address")
+	ErrSlotFull          = errors.New("delivery slot is full")
+	ErrSlotClosed        = errors.New("delivery slot has already started")
+	ErrSlotOverbooked    = errors.New("delivery slot has more bookings than its capacity")
+	ErrNotDeliverable    = errors.New("nothing to deliver or pick up")
+	ErrAlreadyScheduled  = errors.New("delivery already scheduled")
+	ErrDeliveryCompleted = errors.New("delivery already completed")
+)
+
+type DeliverySlot struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Capacity  int       `json:"capacity"`
+	Booked    int       `json:"booked"`
+	Version   int       `json:"version"`
+}
+
+func ValidateDeliverySlot(v *validator.Validator, slot *DeliverySlot) {
+	v.Check(!slot.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!slot.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(slot.EndsAt.After(slot.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(slot.EndsAt.Sub(slot.StartsAt) <= 12*time.Hour, "ends_at", "must not be more than 12 hours after starts_at")
+	v.Check(slot.Capacity >= 0, "capacity", "must not be negative")
+	v.Check(slot.Capacity <= 1000, "capacity", "must not be more than 1000")
+}
+
+type Delivery struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Kind        string     `json:"kind"`
+	UserID      int64      `json:"user_id"`
+	UserName    string     `json:"user_name,omitempty"`
+	AddressID   int64      `json:"address_id"`
+	Address     *Address   `json:"address,omitempty"`
+	SlotID      int64      `json:"slot_id"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	CheckoutID  int64      `json:"checkout_id,omitempty"`
+	LoanID      int64      `json:"loan_id,omitempty"`
+	Status      string     `json:"status"`
+	CourierID   int64      `json:"courier_id,omitempty"`
+	Note        string     `json:"note,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Version     int        `json:"version"`
+}
+
+func ValidateDelivery(v *validator.Validator, delivery *Delivery) {
+	v.Check(validator.PermittedValue(delivery.Kind, DeliveryDropOff, DeliveryPickup), "kind", "must be delivery or pickup")
+	v.Check(delivery.SlotID > 0, "slot_id", "must be provided")
+	v.Check(delivery.AddressID > 0, "address_id", "must be provided")
+
+	switch delivery.Kind {
+	case DeliveryDropOff:
+		v.Check(delivery.CheckoutID > 0, "checkout_id", "must be provided")
+		v.Check(delivery.LoanID == 0, "loan_id", "must not be provided for a delivery")
+	case DeliveryPickup:
+		v.Check(delivery.LoanID > 0, "loan_id", "must be provided")
+		v.Check(delivery.CheckoutID == 0, "checkout_id", "must not be provided for a pickup")
+	}
+}
+
+func ValidateDeliveryStatus(v *validator.Validator, status string) {
+	v.Check(validator.PermittedValue(status, DeliveryScheduled, DeliveryDelivered, DeliveryFailed, DeliveryCancelled), "status", "must be scheduled, delivered, failed or cancelled")
+}
+
+type DeliverySlotModel struct {
+	DB *sql.DB
+}
+
+func (d DeliverySlotModel) Insert(slot *DeliverySlot) error {
+	query := `
+INSERT INTO delivery_slots (starts_at, ends_at, capacity)
+VALUES ($1, $2, $3)
+RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return d.DB.QueryRowContext(ctx, query, slot.StartsAt, slot.EndsAt, slot.Capacity).Scan(&slot.ID, &slot.CreatedAt, &slot.Version)
+}
+
+func (d DeliverySlotModel) Get(id int64) (*DeliverySlot, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, starts_at, ends_at, capacity, version,
+	(SELECT count(*) FROM deliveries WHERE deliveries.slot_id = delivery_slots.id AND deliveries.status <> 'cancelled')
+FROM delivery_slots
+WHERE id = $1`
+
+	var slot DeliverySlot
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := d.DB.QueryRowContext(ctx, query, id).Scan(
+		&slot.ID,
+		&slot.CreatedAt,
+		&slot.StartsAt,
+		&slot.EndsAt,
+		&slot.Capacity,
+		&slot.Version,
+		&slot.Booked,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &slot, nil
+}
+
+// Update saves a slot's period and capacity. The capacity can't be lowered
+// below the number of bookings the slot already has.
+func (d DeliverySlotModel) Update(slot *DeliverySlot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	booked, err := lockSlot(ctx, tx, slot.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownSlot):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	if slot.Capacity < booked {
+		return ErrSlotOverbooked
+	}
+
+	query := `
+UPDATE delivery_slots
+SET starts_at = $1, ends_at = $2, capacity = $3, version = version + 1
+WHERE id = $4 AND version = $5
+RETURNING version`
+
+	args := []any{slot.StartsAt, slot.EndsAt, slot.Capacity, slot.ID, slot.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&slot.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	slot.Booked = booked
+
+	return tx.Commit()
+}
+
+// GetAllBetween lists the slots starting in [from, to) with their bookings.
+func (d DeliverySlotModel) GetAllBetween(from, to time.Time) ([]*DeliverySlot, error) {
+	query := `
+SELECT id, created_at, starts_at, ends_at, capacity, version,
+	(SELECT count(*) FROM deliveries WHERE deliveries.slot_id = delivery_slots.id AND deliveries.status <> 'cancelled')
+FROM delivery_slots
+WHERE starts_at >= $1 AND starts_at < $2
+ORDER BY starts_at ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := d.DB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []*DeliverySlot{}
+
+	for rows.Next() {
+		var slot DeliverySlot
+
+		err := rows.Scan(
+			&slot.ID,
+			&slot.CreatedAt,
+			&slot.StartsAt,
+			&slot.EndsAt,
+			&slot.Capacity,
+			&slot.Version,
+			&slot.Booked,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, &slot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
+
+// lockSlot takes the slot's row lock, which serialises bookings of the slot,
+// and returns how many bookings it already has.
+func lockSlot(ctx context.Context, tx *sql.Tx, slotID int64) (int, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM delivery_slots WHERE id = $1 FOR UPDATE`, slotID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrUnknownSlot
+		default:
+			return 0, err
+		}
+	}
+
+	var booked int
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM deliveries WHERE slot_id = $1 AND status <> 'cancelled'`, slotID).Scan(&booked)
+	if err != nil {
+		return 0, err
+	}
+
+	return booked, nil
+}
+
+type DeliveryModel struct {
+	DB *sql.DB
+}
+
+const deliveryColumns = `
+deliveries.id, deliveries.created_at, deliveries.kind, deliveries.user_id, deliveries.address_id, deliveries.slot_id,
+delivery_slots.starts_at, delivery_slots.ends_at, COALESCE(deliveries.checkout_id, 0), COALESCE(deliveries.loan_id, 0),
+deliveries.status, COALESCE(deliveries.courier_id, 0), deliveries.note, deliveries.completed_at, deliveries.version`
+
+func deliveryDest(delivery *Delivery) []any {
+	return []any{
+		&delivery.ID,
+		&delivery.CreatedAt,
+		&delivery.Kind,
+		&delivery.UserID,
+		&delivery.AddressID,
+		&delivery.SlotID,
+		&delivery.StartsAt,
+		&delivery.EndsAt,
+		&delivery.CheckoutID,
+		&delivery.LoanID,
+		&delivery.Status,
+		&delivery.CourierID,
+		&delivery.Note,
+		&delivery.CompletedAt,
+		&delivery.Version,
+	}
+}
+
+// Insert books a slot for bringing the toys of a checkout request to the user
+// or for picking up a loaned toy. The slot's row lock keeps concurrent
+// bookings from overfilling it.
+func (d DeliveryModel) Insert(delivery *Delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	booked, err := lockSlot(ctx, tx, delivery.SlotID)
+	if err != nil {
+		return err
+	}
+
+	var capacity int
+	err = tx.QueryRowContext(ctx, `SELECT starts_at, ends_at, capacity FROM delivery_slots WHERE id = $1`, delivery.SlotID).Scan(&delivery.StartsAt, &delivery.EndsAt, &capacity)
+	if err != nil {
+		return err
+	}
+	if !delivery.StartsAt.After(time.Now()) {
+		return ErrSlotClosed
+	}
+	if booked >= capacity {
+		return ErrSlotFull
+	}
+
+	var addressExists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM addresses WHERE id = $1 AND user_id = $2)`, delivery.AddressID, delivery.UserID).Scan(&addressExists)
+	if err != nil {
+		return err
+	}
+	if !addressExists {
+		return ErrUnknownAddress
+	}
+
+	var deliverable bool
+
+	switch delivery.Kind {
+	case DeliveryDropOff:
+		err = tx.QueryRowContext(ctx, `SELECT status <> 'rejected' FROM checkout_requests WHERE id = $1 AND user_id = $2`, delivery.CheckoutID, delivery.UserID).Scan(&deliverable)
+	default:
+		err = tx.QueryRowContext(ctx, `SELECT returned_at IS NULL FROM loans WHERE id = $1 AND user_id = $2`, delivery.LoanID, delivery.UserID).Scan(&deliverable)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	if !deliverable {
+		return ErrNotDeliverable
+	}
+
+	query := `
+INSERT INTO deliveries (kind, user_id, address_id, slot_id, checkout_id, loan_id)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0))
+RETURNING id, created_at, status, version`
+
+	args := []any{delivery.Kind, delivery.UserID, delivery.AddressID, delivery.SlotID, delivery.CheckoutID, delivery.LoanID}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&delivery.ID, &delivery.CreatedAt, &delivery.Status, &delivery.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Constraint {
+			case "deliveries_scheduled_checkout_idx", "deliveries_scheduled_loan_idx":
+				return ErrAlreadyScheduled
+			}
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d DeliveryModel) Get(id int64) (*Delivery, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+SELECT %s
+FROM deliveries
+INNER JOIN delivery_slots ON delivery_slots.id = deliveries.slot_id
+WHERE deliveries.id = $1`, deliveryColumns)
+
+	var delivery Delivery
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := d.DB.QueryRowContext(ctx, query, id).Scan(deliveryDest(&delivery)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &delivery, nil
+}
+
+// GetAll lists bookings with the given status, or with any status when it is
+// empty. A zero userID lists the bookings of every user.
+func (d DeliveryModel) GetAll(userID int64, status string, filters Filters) ([]*Delivery, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), %s
+FROM deliveries
+INNER JOIN delivery_slots ON delivery_slots.id = deliveries.slot_id
+WHERE (deliveries.user_id = $1 OR $1 = 0)
+AND (deliveries.status = $2 OR $2 = '')
+ORDER BY deliveries.%s %s, deliveries.id ASC
+LIMIT $3 OFFSET $4`, deliveryColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := d.DB.QueryContext(ctx, query, userID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*Delivery{}
+
+	for rows.Next() {
+		var delivery Delivery
+
+		err := rows.Scan(append([]any{&totalRecords}, deliveryDest(&delivery)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// GetJobs lists the bookings whose slots start in [from, to), in the order a
+// courier works through them, with the address and the customer's name.
+func (d DeliveryModel) GetJobs(from, to time.Time, status string) ([]*Delivery, error) {
+	query := fmt.Sprintf(`
+SELECT %s,
+	addresses.id, addresses.user_id, addresses.label, addresses.line1, addresses.line2, addresses.city,
+	addresses.postal_code, addresses.phone, addresses.notes, addresses.version, users.name
+FROM deliveries
+INNER JOIN delivery_slots ON delivery_slots.id = deliveries.slot_id
+INNER JOIN addresses ON addresses.id = deliveries.address_id
+INNER JOIN users ON users.id = deliveries.user_id
+WHERE delivery_slots.starts_at >= $1 AND delivery_slots.starts_at < $2
+AND (deliveries.status = $3 OR $3 = '')
+ORDER BY delivery_slots.starts_at ASC, addresses.city ASC, deliveries.id ASC`, deliveryColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := d.DB.QueryContext(ctx, query, from, to, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*Delivery{}
+
+	for rows.Next() {
+		var (
+			job     Delivery
+			address Address
+		)
+
+		dest := append(deliveryDest(&job),
+			&address.ID,
+			&address.UserID,
+			&address.Label,
+			&address.Line1,
+			&address.Line2,
+			&address.City,
+			&address.PostalCode,
+			&address.Phone,
+			&address.Notes,
+			&address.Version,
+			&job.UserName,
+		)
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		job.Address = &address
+		jobs = append(jobs, &job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Cancel calls off a scheduled delivery or pickup. It can't be cancelled once
+// its slot has started, as the courier may already be on the way.
+func (d DeliveryModel) Cancel(delivery *Delivery) error {
+	if delivery.Status == DeliveryScheduled && !delivery.StartsAt.After(time.Now()) {
+		return ErrSlotClosed
+	}
+
+	return d.Complete(delivery, DeliveryCancelled, 0, "")
+}
+
+// Complete records the outcome of a scheduled delivery or pickup. Status must
+// be delivered or failed when done by a courier and cancelled when the user
+// calls the booking off, which Cancel checks is still allowed.
+func (d DeliveryModel) Complete(delivery *Delivery, status string, courierID int64, note string) error {
+	if delivery.Status != DeliveryScheduled {
+		return ErrDeliveryCompleted
+	}
+
+	query := `
+UPDATE deliveries
+SET status = $1, courier_id = NULLIF($2, 0), note = $3, completed_at = now(), version = version + 1
+WHERE id = $4 AND version = $5 AND status = 'scheduled'
+AND ($1 <> 'cancelled' OR EXISTS (SELECT 1 FROM delivery_slots WHERE id = deliveries.slot_id AND starts_at > now()))
+RETURNING status, COALESCE(courier_id, 0), note, completed_at, version`
+
+	args := []any{status, courierID, note, delivery.ID, delivery.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := d.DB.QueryRowContext(ctx, query, args...).Scan(
+		&delivery.Status,
+		&delivery.CourierID,
+		&delivery.Note,
+		&delivery.CompletedAt,
+		&delivery.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+type FacetCount struct {
+	// ID is the id of the value, for facets filtered by id.
+	ID    int64  `json:"id,omitempty"`
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the toys matching a search by each value of the catalog's
+// filterable fields. A toy is counted once for every category and skill it
+// has and for every age band its age range overlaps.
+type Facets struct {
+	Categories    []FacetCount `json:"categories"`
+	Skills        []FacetCount `json:"skills"`
+	Manufacturers []FacetCount `json:"manufacturers"`
+	AgeBands      []FacetCount `json:"age_bands"`
+	ValueBuckets  []FacetCount `json:"value_buckets"`
+}
+
+// toyAgeBands are the bands of the age facet, with their lowest and highest
+// month. toyValueBuckets are the buckets of the value facet in tenge; their
+// labels are the from and to filters that select them.
+const (
+	toyAgeBands = `(VALUES
+	(1, '0-1 years', 0, 11),
+	(2, '1-3 years', 12, 35),
+	(3, '3-5 years', 36, 59),
+	(4, '5-8 years', 60, 95),
+	(5, '8-12 years', 96, 143),
+	(6, '12+ years', 144, NULL)
+) AS band (position, label, low, high)`
+
+	toyValueBuckets = `(VALUES
+	(1, '0-4999', 0, 4999),
+	(2, '5000-9999', 5000, 9999),
+	(3, '10000-19999', 10000, 19999),
+	(4, '20000-49999', 20000, 49999),
+	(5, '50000+', 50000, NULL)
+) AS bucket (position, label, low, high)`
+)
+
+// GetFacets computes every facet of a search in a single pass over the
+// matching toys.
+func (t ToyModel) GetFacets(search ToySearch) (*Facets, error) {
+	search, err := t.expandTerms(search)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+WITH matched AS (
+	SELECT categories, skills, manufacturer_id, manufacturer, min_age_months, max_age_months, value
+	FROM toys
+	WHERE ` + search.conditions() + `
+)
+SELECT 'category', 0, category, count(*), 0
+FROM matched, unnest(categories) AS category
+GROUP BY category
+UNION ALL
+SELECT 'skill', 0, skill, count(*), 0
+FROM matched, unnest(skills) AS skill
+GROUP BY skill
+UNION ALL
+SELECT 'manufacturer', manufacturer_id, manufacturer, count(*), 0
+FROM matched
+WHERE manufacturer_id IS NOT NULL
+GROUP BY manufacturer_id, manufacturer
+UNION ALL
+SELECT 'age', 0, band.label, count(matched.*), band.position
+FROM ` + toyAgeBands + `
+LEFT JOIN matched ON matched.min_age_months <= COALESCE(band.high, matched.min_age_months)
+	AND (matched.max_age_months IS NULL OR matched.max_age_months >= band.low)
+GROUP BY band.label, band.position
+UNION ALL
+SELECT 'value', 0, bucket.label, count(matched.*), bucket.position
+FROM ` + toyValueBuckets + `
+LEFT JOIN matched ON matched.value >= bucket.low AND (bucket.high IS NULL OR matched.value <= bucket.high)
+GROUP BY bucket.label, bucket.position
+ORDER BY 1, 5, 4 DESC, 3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, search.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := Facets{
+		Categories:    []FacetCount{},
+		Skills:        []FacetCount{},
+		Manufacturers: []FacetCount{},
+		AgeBands:      []FacetCount{},
+		ValueBuckets:  []FacetCount{},
+	}
+
+	for rows.Next() {
+		var (
+			facet    string
+			count    FacetCount
+			position int
+		)
+
+		err := rows.Scan(&facet, &count.ID, &count.Value, &count.Count, &position)
+		if err != nil {
+			return nil, err
+		}
+
+		switch facet {
+		case "category":
+			facets.Categories = append(facets.Categories, count)
+		case "skill":
+			facets.Skills = append(facets.Skills, count)
+		case "manufacturer":
+			facets.Manufacturers = append(facets.Manufacturers, count)
+		case "age":
+			facets.AgeBands = append(facets.AgeBands, count)
+		case "value":
+			facets.ValueBuckets = append(facets.ValueBuckets, count)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &facets, nil
+}
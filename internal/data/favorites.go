@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+// ValidateFavoriteCategories checks the categories a user picked as their
+// favorites against the taxonomy.
+func ValidateFavoriteCategories(v *validator.Validator, categories []string, taxonomy *Taxonomy) {
+	v.Check(categories != nil, "categories", "must be provided")
+	v.Check(len(categories) <= 10, "categories", "must not contain more than 10 categories")
+	v.Check(validator.Unique(categories), "categories", "must not contain duplicate values")
+
+	for _, category := range categories {
+		v.Check(taxonomy.Categories[category], "categories", fmt.Sprintf("%q is not a known category", category))
+	}
+}
+
+type FavoriteCategoryModel struct {
+	DB *sql.DB
+}
+
+func (f FavoriteCategoryModel) Get(userID int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	categories := []string{}
+
+	err := f.DB.QueryRowContext(ctx, `SELECT favorite_categories FROM users WHERE id = $1`, userID).Scan(pq.Array(&categories))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return categories, nil
+}
+
+// Set replaces the user's favorite categories.
+func (f FavoriteCategoryModel) Set(userID int64, categories []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := f.DB.ExecContext(ctx, `UPDATE users SET favorite_categories = $2 WHERE id = $1`, userID, pq.Array(categories))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -1,24 +1,36 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"oynas/internal/validator"
 	"strings"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Cursor is a token from Metadata.NextCursor or Metadata.PrevCursor. When
+	// it is set, the page after or before the record it points at is listed
+	// instead of Page.
+	Cursor string
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 func (f Filters) calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -40,6 +52,9 @@ func (f Filters) limit() int {
 }
 
 func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
 	return (f.Page - 1) * f.PageSize
 }
 
@@ -50,6 +65,12 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "maximum is 100")
 
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort type")
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "must be a valid cursor")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "was made for another sort")
+	}
 }
 
 func (f Filters) sortColumn() string {
@@ -68,3 +89,134 @@ func (f Filters) sortDirection() string {
 	}
 	return "ASC"
 }
+
+// cursor is the content of a cursor token: the sort it was made for and the
+// sort keys of the record it points at, as Postgres text.
+type cursor struct {
+	Sort     string   `json:"s"`
+	Keys     []string `json:"k"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+
+	js, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || len(c.Keys) == 0 {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// sortKey is an SQL expression a cursor-paginated query is ordered by. The
+// last key of a query must be unique, usually the id.
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// keyset returns the condition and ORDER BY list of a query ordered by keys.
+// With a cursor, the condition selects the records after it, or before it for
+// a backward cursor, and takes the cursor's keys as query parameters numbered
+// from firstParam on. Without one, the condition is TRUE.
+func (f Filters) keyset(keys []sortKey, firstParam int) (where, orderBy string, args []any, err error) {
+	backward := false
+
+	where = "TRUE"
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if len(c.Keys) != len(keys) {
+			return "", "", nil, ErrInvalidCursor
+		}
+		backward = c.Backward
+
+		// (k1 > $1) OR (k1 = $1 AND k2 > $2) OR ..., with < for keys ordered
+		// the other way round, since directions may differ between keys.
+		var or []string
+		for i, key := range keys {
+			var and []string
+			for j := 0; j < i; j++ {
+				and = append(and, fmt.Sprintf("%s = $%d", keys[j].expr, firstParam+j))
+			}
+
+			op := ">"
+			if key.desc != backward {
+				op = "<"
+			}
+			and = append(and, fmt.Sprintf("%s %s $%d", key.expr, op, firstParam+i))
+
+			or = append(or, "("+strings.Join(and, " AND ")+")")
+		}
+		where = "(" + strings.Join(or, " OR ") + ")"
+
+		for _, key := range c.Keys {
+			args = append(args, key)
+		}
+	}
+
+	var order []string
+	for _, key := range keys {
+		direction := "ASC"
+		if key.desc != backward {
+			direction = "DESC"
+		}
+		order = append(order, key.expr+" "+direction)
+	}
+
+	return where, strings.Join(order, ", "), args, nil
+}
+
+// calculateKeysetMetadata adds cursors to the metadata of a page listed with
+// keyset, given the sort keys of its records as Postgres text. The records
+// and their keys are put back in order when the cursor was a backward one.
+// totalRecords is the count of every record matching the query, which with a
+// cursor counts only those on its side.
+func calculateKeysetMetadata[T any](f Filters, totalRecords int, records []T, keys [][]string) Metadata {
+	var metadata Metadata
+	var hasNext, hasPrev bool
+
+	if f.Cursor == "" {
+		metadata = f.calculateMetadata(totalRecords, f.Page, f.PageSize)
+		hasNext = f.offset()+len(records) < totalRecords
+		hasPrev = f.Page > 1
+	} else {
+		c, _ := decodeCursor(f.Cursor)
+		more := totalRecords > len(records)
+
+		if c.Backward {
+			for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+				records[i], records[j] = records[j], records[i]
+				keys[i], keys[j] = keys[j], keys[i]
+			}
+		}
+
+		metadata = Metadata{PageSize: f.PageSize}
+		hasNext = more || c.Backward
+		hasPrev = more || !c.Backward
+	}
+
+	if len(records) > 0 {
+		if hasNext {
+			metadata.NextCursor = cursor{Sort: f.Sort, Keys: keys[len(keys)-1]}.encode()
+		}
+		if hasPrev {
+			metadata.PrevCursor = cursor{Sort: f.Sort, Keys: keys[0], Backward: true}.encode()
+		}
+	}
+
+	return metadata
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"oynas/internal/validator"
+	"reflect"
+	"testing"
+)
+
+// mixedKeys sorts by one column ascending and another descending, with the id
+// as the unique last key.
+var mixedKeys = []sortKey{{expr: "value"}, {expr: "rating_average", desc: true}, {expr: "id"}}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		where   string
+		orderBy string
+		args    []any
+	}{
+		{
+			name:    "without cursor",
+			where:   "TRUE",
+			orderBy: "value ASC, rating_average DESC, id ASC",
+		},
+		{
+			name:    "forward cursor",
+			cursor:  cursor{Sort: "value", Keys: []string{"5000", "4.5", "7"}}.encode(),
+			where:   "((value > $12) OR (value = $12 AND rating_average < $13) OR (value = $12 AND rating_average = $13 AND id > $14))",
+			orderBy: "value ASC, rating_average DESC, id ASC",
+			args:    []any{"5000", "4.5", "7"},
+		},
+		{
+			name:    "backward cursor",
+			cursor:  cursor{Sort: "value", Keys: []string{"5000", "4.5", "7"}, Backward: true}.encode(),
+			where:   "((value < $12) OR (value = $12 AND rating_average > $13) OR (value = $12 AND rating_average = $13 AND id < $14))",
+			orderBy: "value DESC, rating_average ASC, id DESC",
+			args:    []any{"5000", "4.5", "7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filters{Sort: "value", Cursor: tt.cursor}
+
+			where, orderBy, args, err := f.keyset(mixedKeys, 12)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if where != tt.where {
+				t.Errorf("got where %q; want %q", where, tt.where)
+			}
+			if orderBy != tt.orderBy {
+				t.Errorf("got order by %q; want %q", orderBy, tt.orderBy)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("got args %v; want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestKeysetRejectsTamperedCursors(t *testing.T) {
+	valid := cursor{Sort: "value", Keys: []string{"5000", "4.5", "7"}}.encode()
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"truncated", valid[:len(valid)-4]},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("value,5000"))},
+		{"no keys", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"value","k":[]}`))},
+		{"keys removed", cursor{Sort: "value", Keys: []string{"5000", "7"}}.encode()},
+		{"keys added", cursor{Sort: "value", Keys: []string{"5000", "4.5", "7", "8"}}.encode()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filters{Sort: "value", Cursor: tt.cursor}
+
+			_, _, _, err := f.keyset(mixedKeys, 12)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got error %v; want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		valid  bool
+	}{
+		{"same sort", cursor{Sort: "value", Keys: []string{"1"}}.encode(), true},
+		{"another sort", cursor{Sort: "-value", Keys: []string{"1"}}.encode(), false},
+		{"tampered", "not a cursor!", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+
+			ValidateFilters(v, Filters{Page: 1, PageSize: 20, Sort: "value", SortSafelist: []string{"value", "-value"}, Cursor: tt.cursor})
+
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %v; want %v (errors %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestCalculateKeysetMetadataBackward(t *testing.T) {
+	f := Filters{Sort: "id", PageSize: 2, Cursor: cursor{Sort: "id", Keys: []string{"5"}, Backward: true}.encode()}
+
+	// A backward page comes from the database in reverse order.
+	records := []int{4, 3}
+	keys := [][]string{{"4"}, {"3"}}
+
+	metadata := calculateKeysetMetadata(f, 4, records, keys)
+
+	if !reflect.DeepEqual(records, []int{3, 4}) {
+		t.Errorf("got records %v; want [3 4]", records)
+	}
+
+	next, err := decodeCursor(metadata.NextCursor)
+	if err != nil || next.Backward || !reflect.DeepEqual(next.Keys, []string{"4"}) {
+		t.Errorf("got next cursor %+v (%v); want forward from 4", next, err)
+	}
+
+	prev, err := decodeCursor(metadata.PrevCursor)
+	if err != nil || !prev.Backward || !reflect.DeepEqual(prev.Keys, []string{"3"}) {
+		t.Errorf("got prev cursor %+v (%v); want backward from 3", prev, err)
+	}
+}
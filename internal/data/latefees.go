@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// OverdueLoan is an open loan past its due date, together with what the
+// overdue processing needs to charge and notify the borrower.
+type OverdueLoan struct {
+	Loan
+	UserName    string `json:"user_name"`
+	UserEmail   string `json:"user_email"`
+	ToyValue    int64  `json:"toy_value"`
+	DaysOverdue int    `json:"days_overdue"`
+	LateFees    int64  `json:"late_fees"`
+}
+
+func lateFeeKeyPrefix(loanID int64) string {
+	return fmt.Sprintf("late-fee:%d:", loanID)
+}
+
+// lateFee is the fee charged for each day a toy of the given value is late.
+func lateFee(value int64, dailyRate float64) int64 {
+	return int64(math.Round(float64(value) * dailyRate))
+}
+
+func (l LoanModel) GetOverdue(filters Filters) ([]*OverdueLoan, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), loans.id, loans.created_at, loans.toy_id, toys.title, loans.unit_id, loans.user_id, loans.due_date,
+	loans.returned_at, loans.version, users.name, users.email, COALESCE(toys.value, 0),
+	floor(extract(epoch FROM now() - loans.due_date) / 86400)::integer,
+	COALESCE((
+		SELECT -sum(ledger_entries.amount) FROM ledger_entries
+		WHERE ledger_entries.user_id = loans.user_id AND ledger_entries.idempotency_key LIKE 'late-fee:' || loans.id || ':%%'
+	), 0)
+FROM loans
+INNER JOIN toys ON toys.id = loans.toy_id
+INNER JOIN users ON users.id = loans.user_id
+WHERE loans.returned_at IS NULL AND loans.due_date < now()
+ORDER BY loans.%s %s, loans.id ASC
+LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := l.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	loans := []*OverdueLoan{}
+
+	for rows.Next() {
+		var loan OverdueLoan
+
+		err := rows.Scan(
+			&totalRecords,
+			&loan.ID,
+			&loan.CreatedAt,
+			&loan.ToyID,
+			&loan.ToyTitle,
+			&loan.UnitID,
+			&loan.UserID,
+			&loan.DueDate,
+			&loan.ReturnedAt,
+			&loan.Version,
+			&loan.UserName,
+			&loan.UserEmail,
+			&loan.ToyValue,
+			&loan.DaysOverdue,
+			&loan.LateFees,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		loans = append(loans, &loan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return loans, metadata, nil
+}
+
+// ChargeLateFees posts one charge for every full day the loan has been
+// overdue and not charged for yet, so a day missed by the overdue job is made
+// up on the next run. Each day's charge has its own idempotency key, which
+// keeps concurrent or repeated runs from charging a day twice. Late fees for a
+// loan never add up to more than the toy's value. It returns the charges that
+// were posted by this call.
+func (l LoanModel) ChargeLateFees(loanID int64, dailyRate float64) ([]*LedgerEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		userID     int64
+		dueDate    time.Time
+		returnedAt *time.Time
+		value      int64
+	)
+
+	query := `
+SELECT loans.user_id, loans.due_date, loans.returned_at, COALESCE(toys.value, 0)
+FROM loans
+INNER JOIN toys ON toys.id = loans.toy_id
+WHERE loans.id = $1
+FOR UPDATE OF loans`
+
+	err = tx.QueryRowContext(ctx, query, loanID).Scan(&userID, &dueDate, &returnedAt, &value)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	charges := []*LedgerEntry{}
+
+	daily := lateFee(value, dailyRate)
+	if returnedAt != nil || daily <= 0 {
+		return charges, nil
+	}
+
+	var charged int64
+	err = tx.QueryRowContext(ctx, `
+SELECT COALESCE(-sum(amount), 0) FROM ledger_entries
+WHERE user_id = $1 AND idempotency_key LIKE $2 || '%'`, userID, lateFeeKeyPrefix(loanID)).Scan(&charged)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for day := dueDate.Add(24 * time.Hour); !day.After(now) && charged < value; day = day.Add(24 * time.Hour) {
+		key := lateFeeKeyPrefix(loanID) + day.Format("2006-01-02")
+
+		exists, err := ledgerEntryExists(ctx, tx, userID, key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+
+		fee := daily
+		if fee > value-charged {
+			fee = value - charged
+		}
+
+		charge := &LedgerEntry{
+			UserID:         userID,
+			Kind:           LedgerCharge,
+			Amount:         -fee,
+			Description:    fmt.Sprintf("late fee for loan %d (%s)", loanID, day.Format("2006-01-02")),
+			IdempotencyKey: key,
+		}
+
+		_, err = postEntry(ctx, tx, charge, true)
+		if err != nil {
+			return nil, err
+		}
+
+		charged += fee
+		charges = append(charges, charge)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return charges, nil
+}
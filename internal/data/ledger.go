@@ -0,0 +1,240 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"oynas/internal/validator"
+	"time"
+)
+
+const (
+	LedgerTopUp      = "top_up"
+	LedgerCharge     = "charge"
+	LedgerRefund     = "refund"
+	LedgerAdjustment = "adjustment"
+)
+
+var (
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+)
+
+// LedgerEntry is one append-only movement of a user's balance. Amount is
+// signed: top-ups and refunds are positive, charges are negative.
+type LedgerEntry struct {
+	ID             int64     `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UserID         int64     `json:"user_id"`
+	Kind           string    `json:"kind"`
+	Amount         int64     `json:"amount"`
+	BalanceAfter   int64     `json:"balance_after"`
+	Description    string    `json:"description"`
+	IdempotencyKey string    `json:"-"`
+}
+
+type Reconciliation struct {
+	Balance       int64 `json:"balance"`
+	LedgerBalance int64 `json:"ledger_balance"`
+	InSync        bool  `json:"in_sync"`
+}
+
+func ValidateIdempotencyKey(v *validator.Validator, key string) {
+	v.Check(key != "", "idempotency_key", "must be provided")
+	v.Check(len(key) <= 255, "idempotency_key", "must not be more than 255 bytes long")
+}
+
+func ValidateLedgerEntry(v *validator.Validator, entry *LedgerEntry) {
+	v.Check(entry.UserID > 0, "user_id", "must be provided")
+	v.Check(validator.PermittedValue(entry.Kind, LedgerTopUp, LedgerCharge, LedgerRefund, LedgerAdjustment), "kind", "must be top_up, charge, refund or adjustment")
+	v.Check(entry.Amount != 0, "amount", "must not be zero")
+
+	switch entry.Kind {
+	case LedgerTopUp, LedgerRefund:
+		v.Check(entry.Amount > 0, "amount", "must be positive")
+	case LedgerCharge:
+		v.Check(entry.Amount < 0, "amount", "must be negative")
+	}
+
+	v.Check(len(entry.Description) <= 500, "description", "must not be more than 500 bytes long")
+	ValidateIdempotencyKey(v, entry.IdempotencyKey)
+}
+
+type LedgerModel struct {
+	DB *sql.DB
+}
+
+// Post appends an entry to the ledger and moves the user's balance with it.
+// It reports false when the idempotency key had already been used, in which
+// case entry is filled with the original entry and nothing new is written.
+func (l LedgerModel) Post(entry *LedgerEntry, allowOverdraft bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	created, err := postEntry(ctx, tx, entry, allowOverdraft)
+	if err != nil {
+		return false, err
+	}
+
+	return created, tx.Commit()
+}
+
+// postEntry is Post inside an existing transaction, so that a charge can be
+// committed together with whatever it pays for.
+func postEntry(ctx context.Context, tx *sql.Tx, entry *LedgerEntry, allowOverdraft bool) (bool, error) {
+	var balance int64
+
+	err := tx.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = $1 FOR UPDATE`, entry.UserID).Scan(&balance)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrUnknownUser
+		default:
+			return false, err
+		}
+	}
+
+	var existing LedgerEntry
+
+	query := `
+SELECT id, created_at, user_id, kind, amount, balance_after, description, idempotency_key
+FROM ledger_entries
+WHERE user_id = $1 AND idempotency_key = $2`
+
+	err = tx.QueryRowContext(ctx, query, entry.UserID, entry.IdempotencyKey).Scan(
+		&existing.ID,
+		&existing.CreatedAt,
+		&existing.UserID,
+		&existing.Kind,
+		&existing.Amount,
+		&existing.BalanceAfter,
+		&existing.Description,
+		&existing.IdempotencyKey,
+	)
+	switch {
+	case err == nil:
+		if existing.Kind != entry.Kind || existing.Amount != entry.Amount {
+			return false, ErrIdempotencyKeyReused
+		}
+		*entry = existing
+		return false, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+
+	entry.BalanceAfter = balance + entry.Amount
+	if entry.Amount < 0 && entry.BalanceAfter < 0 && !allowOverdraft {
+		return false, ErrInsufficientFunds
+	}
+
+	query = `
+INSERT INTO ledger_entries (user_id, kind, amount, balance_after, description, idempotency_key)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at`
+
+	args := []any{entry.UserID, entry.Kind, entry.Amount, entry.BalanceAfter, entry.Description, entry.IdempotencyKey}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET balance = $1, version = version + 1 WHERE id = $2`, entry.BalanceAfter, entry.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func ledgerEntryExists(ctx context.Context, tx *sql.Tx, userID int64, idempotencyKey string) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM ledger_entries WHERE user_id = $1 AND idempotency_key = $2)`, userID, idempotencyKey).Scan(&exists)
+	return exists, err
+}
+
+func (l LedgerModel) GetAllForUser(userID int64, kind string, filters Filters) ([]*LedgerEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, user_id, kind, amount, balance_after, description, idempotency_key
+FROM ledger_entries
+WHERE user_id = $1
+AND (kind = $2 OR $2 = '')
+ORDER BY %s %s, id ASC
+LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := l.DB.QueryContext(ctx, query, userID, kind, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*LedgerEntry{}
+
+	for rows.Next() {
+		var entry LedgerEntry
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.UserID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.BalanceAfter,
+			&entry.Description,
+			&entry.IdempotencyKey,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
+
+// Reconcile compares the balance cached on the user row with the sum of the
+// user's ledger entries.
+func (l LedgerModel) Reconcile(userID int64) (*Reconciliation, error) {
+	query := `
+SELECT users.balance, COALESCE((SELECT sum(amount) FROM ledger_entries WHERE ledger_entries.user_id = users.id), 0)
+FROM users
+WHERE users.id = $1`
+
+	var reconciliation Reconciliation
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := l.DB.QueryRowContext(ctx, query, userID).Scan(&reconciliation.Balance, &reconciliation.LedgerBalance)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	reconciliation.InSync = reconciliation.Balance == reconciliation.LedgerBalance
+
+	return &reconciliation, nil
+}
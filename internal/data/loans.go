@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"oynas/internal/validator"
+	"time"
+)
+
+const (
+	LoanStatusActive   = "active"
+	LoanStatusReturned = "returned"
+	LoanStatusAll      = "all"
+)
+
+var (
+	ErrToyUnavailable = errors.New("toy is not available")
+	ErrUnknownUser    = errors.New("unknown user")
+	ErrLoanReturned   = errors.New("loan already returned")
+)
+
+type Loan struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ToyID      int64      `json:"toy_id"`
+	ToyTitle   string     `json:"toy_title,omitempty"`
+	UnitID     int64      `json:"unit_id"`
+	UserID     int64      `json:"user_id"`
+	ChildID    int64      `json:"child_id,omitempty"`
+	DueDate    time.Time  `json:"due_date"`
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+	Version    int        `json:"-"`
+}
+
+func (l *Loan) IsReturned() bool {
+	return l.ReturnedAt != nil
+}
+
+func ValidateLoan(v *validator.Validator, loan *Loan) {
+	v.Check(loan.ToyID > 0, "toy_id", "must be provided")
+	v.Check(loan.UserID > 0, "user_id", "must be provided")
+	v.Check(!loan.DueDate.IsZero(), "due_date", "must be provided")
+	v.Check(loan.DueDate.After(time.Now()), "due_date", "must be in the future")
+	v.Check(loan.DueDate.Before(time.Now().Add(90*24*time.Hour)), "due_date", "must not be more than 90 days from now")
+}
+
+func ValidateLoanStatus(v *validator.Validator, status string) {
+	v.Check(validator.PermittedValue(status, LoanStatusActive, LoanStatusReturned, LoanStatusAll), "status", "must be active, returned or all")
+}
+
+type LoanModel struct {
+	DB *sql.DB
+}
+
+func (l LoanModel) Insert(loan *Loan) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertLoan(ctx, tx, loan)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertLoan checks one unit of a toy out inside an existing transaction. The
+// toy row is locked first so that concurrent checkouts of the same toy are
+// serialized.
+func insertLoan(ctx context.Context, tx *sql.Tx, loan *Loan) error {
+	err := tx.QueryRowContext(ctx, `SELECT title FROM toys WHERE id = $1 AND archived_at IS NULL FOR UPDATE`, loan.ToyID).Scan(&loan.ToyTitle)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = checkPlanLimits(ctx, tx, loan.UserID, []int64{loan.ToyID})
+	if err != nil {
+		return err
+	}
+
+	if loan.ChildID != 0 {
+		var childExists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM children WHERE id = $1 AND user_id = $2)`, loan.ChildID, loan.UserID).Scan(&childExists)
+		if err != nil {
+			return err
+		}
+		if !childExists {
+			return ErrUnknownChild
+		}
+	}
+
+	loan.UnitID, err = reserveUnit(ctx, tx, loan.ToyID, loan.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+INSERT INTO loans (toy_id, unit_id, user_id, child_id, due_date)
+VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+RETURNING id, created_at, version`
+
+	args := []any{loan.ToyID, loan.UnitID, loan.UserID, loan.ChildID, loan.DueDate}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&loan.ID, &loan.CreatedAt, &loan.Version)
+}
+
+func (l LoanModel) Get(id int64) (*Loan, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT loans.id, loans.created_at, loans.toy_id, toys.title, loans.unit_id, loans.user_id, COALESCE(loans.child_id, 0), loans.due_date, loans.returned_at, loans.version
+FROM loans
+INNER JOIN toys ON toys.id = loans.toy_id
+WHERE loans.id = $1`
+
+	var loan Loan
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := l.DB.QueryRowContext(ctx, query, id).Scan(
+		&loan.ID,
+		&loan.CreatedAt,
+		&loan.ToyID,
+		&loan.ToyTitle,
+		&loan.UnitID,
+		&loan.UserID,
+		&loan.ChildID,
+		&loan.DueDate,
+		&loan.ReturnedAt,
+		&loan.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &loan, nil
+}
+
+// MarkReturned closes the loan and sends its unit to cleaning. The unit goes
+// back on the shelf once staff mark it as in stock.
+func (l LoanModel) MarkReturned(loan *Loan) error {
+	if loan.IsReturned() {
+		return ErrLoanReturned
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE loans
+SET returned_at = now(), version = version + 1
+WHERE id = $1 AND version = $2 AND returned_at IS NULL
+RETURNING returned_at, version`
+
+	err = tx.QueryRowContext(ctx, query, loan.ID, loan.Version).Scan(&loan.ReturnedAt, &loan.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE toy_units SET status = 'cleaning', version = version + 1 WHERE id = $1`, loan.UnitID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (l LoanModel) GetAllForUser(userID int64, status string, filters Filters) ([]*Loan, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), loans.id, loans.created_at, loans.toy_id, toys.title, loans.unit_id, loans.user_id, COALESCE(loans.child_id, 0), loans.due_date, loans.returned_at, loans.version
+FROM loans
+INNER JOIN toys ON toys.id = loans.toy_id
+WHERE loans.user_id = $1
+AND ($2 = 'all' OR ($2 = 'active' AND loans.returned_at IS NULL) OR ($2 = 'returned' AND loans.returned_at IS NOT NULL))
+ORDER BY loans.%s %s, loans.id ASC
+LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := l.DB.QueryContext(ctx, query, userID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	loans := []*Loan{}
+
+	for rows.Next() {
+		var loan Loan
+
+		err := rows.Scan(
+			&totalRecords,
+			&loan.ID,
+			&loan.CreatedAt,
+			&loan.ToyID,
+			&loan.ToyTitle,
+			&loan.UnitID,
+			&loan.UserID,
+			&loan.ChildID,
+			&loan.DueDate,
+			&loan.ReturnedAt,
+			&loan.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		loans = append(loans, &loan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return loans, metadata, nil
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateManufacturer = errors.New("duplicate manufacturer")
+	ErrManufacturerInUse     = errors.New("manufacturer has toys")
+	ErrUnknownManufacturer   = errors.New("unknown manufacturer")
+)
+
+type Manufacturer struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country,omitempty"`
+	Website   string    `json:"website,omitempty"`
+	ToyCount  int       `json:"toy_count,omitempty"`
+	Version   int       `json:"version,omitempty"`
+}
+
+func ValidateManufacturer(v *validator.Validator, manufacturer *Manufacturer) {
+	v.Check(strings.TrimSpace(manufacturer.Name) != "", "name", "must be provided")
+	v.Check(len(manufacturer.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(manufacturer.Country) <= 100, "country", "must not be more than 100 bytes long")
+	v.Check(len(manufacturer.Website) <= 500, "website", "must not be more than 500 bytes long")
+	v.Check(manufacturer.Website == "" || strings.HasPrefix(manufacturer.Website, "https://") || strings.HasPrefix(manufacturer.Website, "http://"), "website", "must be an http or https URL")
+}
+
+type ManufacturerModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a manufacturer. Names are unique regardless of case,
+// punctuation and company suffixes, so "Lego Group" can't be added next to
+// "LEGO".
+func (m ManufacturerModel) Insert(manufacturer *Manufacturer) error {
+	query := `
+INSERT INTO manufacturers (name, country, website)
+VALUES ($1, $2, $3)
+RETURNING id, created_at, version`
+
+	args := []any{strings.TrimSpace(manufacturer.Name), manufacturer.Country, manufacturer.Website}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&manufacturer.ID, &manufacturer.CreatedAt, &manufacturer.Version)
+	if err != nil {
+		return manufacturerError(err)
+	}
+	return nil
+}
+
+func (m ManufacturerModel) Get(id int64) (*Manufacturer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, name, country, website,
+	(SELECT count(*) FROM toys WHERE toys.manufacturer_id = manufacturers.id), version
+FROM manufacturers
+WHERE id = $1`
+
+	var manufacturer Manufacturer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&manufacturer.ID,
+		&manufacturer.CreatedAt,
+		&manufacturer.Name,
+		&manufacturer.Country,
+		&manufacturer.Website,
+		&manufacturer.ToyCount,
+		&manufacturer.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &manufacturer, nil
+}
+
+// Update saves a manufacturer. Renaming it renames it on its toys too.
+func (m ManufacturerModel) Update(manufacturer *Manufacturer) error {
+	query := `
+UPDATE manufacturers
+SET name = $1, country = $2, website = $3, version = version + 1
+WHERE id = $4 AND version = $5
+RETURNING version`
+
+	args := []any{strings.TrimSpace(manufacturer.Name), manufacturer.Country, manufacturer.Website, manufacturer.ID, manufacturer.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&manufacturer.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return manufacturerError(err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a manufacturer that no toy references.
+func (m ManufacturerModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM manufacturers WHERE id = $1`, id)
+	if err != nil {
+		return manufacturerError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m ManufacturerModel) GetAll(name string, filters Filters) ([]*Manufacturer, Metadata, error) {
+	query := `
+SELECT count(*) OVER(), id, created_at, name, country, website,
+	(SELECT count(*) FROM toys WHERE toys.manufacturer_id = manufacturers.id), version
+FROM manufacturers
+WHERE (name ILIKE '%' || $1 || '%' OR $1 = '')
+ORDER BY ` + filters.sortColumn() + ` ` + filters.sortDirection() + `, id ASC
+LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, likeEscaper.Replace(name), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	manufacturers := []*Manufacturer{}
+
+	for rows.Next() {
+		var manufacturer Manufacturer
+
+		err := rows.Scan(
+			&totalRecords,
+			&manufacturer.ID,
+			&manufacturer.CreatedAt,
+			&manufacturer.Name,
+			&manufacturer.Country,
+			&manufacturer.Website,
+			&manufacturer.ToyCount,
+			&manufacturer.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		manufacturers = append(manufacturers, &manufacturer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return manufacturers, metadata, nil
+}
+
+func manufacturerError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "manufacturers_name_key":
+			return ErrDuplicateManufacturer
+		case "toys_manufacturer_id_fkey":
+			return ErrManufacturerInUse
+		}
+	}
+	return err
+}
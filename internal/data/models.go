@@ -11,19 +11,53 @@ var (
 )
 
 type Models struct {
-	Toys        ToyModel
-	Permissions PermissionModel
-	Users       UserModel
-	Comment     CommentModel
-	Tokens      TokenModel
+	Toys          ToyModel
+	Permissions   PermissionModel
+	Users         UserModel
+	Comment       CommentModel
+	Tokens        TokenModel
+	Loans         LoanModel
+	WaitList      WaitListModel
+	Bucket        BucketModel
+	Checkouts     CheckoutModel
+	Plans         PlanModel
+	Ledger        LedgerModel
+	Units         ToyUnitModel
+	DamageReports DamageReportModel
+	Addresses     AddressModel
+	DeliverySlots DeliverySlotModel
+	Deliveries    DeliveryModel
+	Children      ChildModel
+	Manufacturers ManufacturerModel
+	Taxonomy      TaxonomyModel
+	ToyRevisions  ToyRevisionModel
+	Bundles       BundleModel
+	Favorites     FavoriteCategoryModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Toys:        ToyModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Users:       UserModel{DB: db},
-		Comment:     CommentModel{DB: db},
-		Tokens:      TokenModel{DB: db},
+		Toys:          ToyModel{DB: db},
+		Permissions:   PermissionModel{DB: db},
+		Users:         UserModel{DB: db},
+		Comment:       CommentModel{DB: db},
+		Tokens:        TokenModel{DB: db},
+		Loans:         LoanModel{DB: db},
+		WaitList:      WaitListModel{DB: db},
+		Bucket:        BucketModel{DB: db},
+		Checkouts:     CheckoutModel{DB: db},
+		Plans:         PlanModel{DB: db},
+		Ledger:        LedgerModel{DB: db},
+		Units:         ToyUnitModel{DB: db},
+		DamageReports: DamageReportModel{DB: db},
+		Addresses:     AddressModel{DB: db},
+		DeliverySlots: DeliverySlotModel{DB: db},
+		Deliveries:    DeliveryModel{DB: db},
+		Children:      ChildModel{DB: db},
+		Manufacturers: ManufacturerModel{DB: db},
+		Taxonomy:      TaxonomyModel{DB: db},
+		ToyRevisions:  ToyRevisionModel{DB: db},
+		Bundles:       BundleModel{DB: db},
+		Favorites:     FavoriteCategoryModel{DB: db},
 	}
 }
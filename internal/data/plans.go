@@ -0,0 +1,426 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	PlanSubscribed = "subscribed"
+	PlanUpgraded   = "upgraded"
+	PlanDowngraded = "downgraded"
+)
+
+var (
+	ErrPlanLimitExceeded = errors.New("plan limit exceeded")
+	ErrNoActivePlan      = errors.New("no active plan")
+	ErrAlreadySubscribed = errors.New("already subscribed to plan")
+)
+
+type Plan struct {
+	ID                int64     `json:"id"`
+	CreatedAt         time.Time `json:"-"`
+	Name              string    `json:"name"`
+	Price             int64     `json:"price"`
+	MaxToys           int       `json:"max_toys"`
+	MaxValue          int64     `json:"max_value"`
+	BillingPeriodDays int       `json:"billing_period_days"`
+	Version           int       `json:"-"`
+}
+
+func (p *Plan) BillingPeriod() time.Duration {
+	return time.Duration(p.BillingPeriodDays) * 24 * time.Hour
+}
+
+type Subscription struct {
+	Plan      *Plan        `json:"plan"`
+	PaidUntil *time.Time   `json:"paid_until,omitempty"`
+	Change    string       `json:"change,omitempty"`
+	Charge    *LedgerEntry `json:"charge,omitempty"`
+}
+
+type PlanModel struct {
+	DB *sql.DB
+}
+
+func (p PlanModel) GetAll() ([]*Plan, error) {
+	query := `
+SELECT id, created_at, name, price, max_toys, max_value, billing_period_days, version
+FROM plans
+ORDER BY price ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*Plan{}
+
+	for rows.Next() {
+		var plan Plan
+
+		err := rows.Scan(
+			&plan.ID,
+			&plan.CreatedAt,
+			&plan.Name,
+			&plan.Price,
+			&plan.MaxToys,
+			&plan.MaxValue,
+			&plan.BillingPeriodDays,
+			&plan.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		plans = append(plans, &plan)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (p PlanModel) Get(id int64) (*Plan, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getPlan(ctx, p.DB, id)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getPlan(ctx context.Context, q queryRower, id int64) (*Plan, error) {
+	query := `
+SELECT id, created_at, name, price, max_toys, max_value, billing_period_days, version
+FROM plans
+WHERE id = $1`
+
+	var plan Plan
+
+	err := q.QueryRowContext(ctx, query, id).Scan(
+		&plan.ID,
+		&plan.CreatedAt,
+		&plan.Name,
+		&plan.Price,
+		&plan.MaxToys,
+		&plan.MaxValue,
+		&plan.BillingPeriodDays,
+		&plan.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &plan, nil
+}
+
+func (p PlanModel) GetSubscription(userID int64) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var (
+		planID    sql.NullInt64
+		paidUntil *time.Time
+	)
+
+	err := p.DB.QueryRowContext(ctx, `SELECT plan_id, paid_until FROM users WHERE id = $1`, userID).Scan(&planID, &paidUntil)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if !planID.Valid {
+		return nil, ErrNoActivePlan
+	}
+
+	plan, err := getPlan(ctx, p.DB, planID.Int64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscription{Plan: plan, PaidUntil: paidUntil}, nil
+}
+
+// Subscribe moves the user onto a plan and charges their balance for it. A
+// user without a running plan pays the full price for a new billing period; an
+// upgrade pays the price difference and keeps the current period, and a
+// downgrade is free. A downgrade is refused while the user holds more than the
+// new plan allows. Every change is recorded under its idempotency key:
+// replaying the key for the same plan returns the recorded change without
+// doing anything, and using it for anything else returns
+// ErrIdempotencyKeyReused.
+func (p PlanModel) Subscribe(userID, planID int64, idempotencyKey string) (*Subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		currentPlanID sql.NullInt64
+		paidUntil     *time.Time
+		bucket        []int64
+	)
+
+	query := `SELECT plan_id, paid_until, bucket FROM users WHERE id = $1 FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&currentPlanID, &paidUntil, pq.Array(&bucket))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	recorded, err := getPlanChange(ctx, tx, userID, idempotencyKey)
+	switch {
+	case err == nil:
+		if recorded.Plan.ID != planID {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return recorded, nil
+	case !errors.Is(err, ErrRecordNotFound):
+		return nil, err
+	}
+
+	// A key already used for a top-up or another charge can't start a
+	// subscription change either.
+	used, err := ledgerEntryExists(ctx, tx, userID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	plan, err := getPlan(ctx, tx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	price := plan.Price
+
+	active := currentPlanID.Valid && paidUntil != nil && paidUntil.After(time.Now())
+
+	subscription := &Subscription{Plan: plan, Change: PlanSubscribed}
+
+	if active {
+		if currentPlanID.Int64 == plan.ID {
+			return nil, ErrAlreadySubscribed
+		}
+
+		current, err := getPlan(ctx, tx, currentPlanID.Int64)
+		if err != nil {
+			return nil, err
+		}
+
+		subscription.Change = PlanUpgraded
+		price = plan.Price - current.Price
+		if plan.Price < current.Price {
+			subscription.Change = PlanDowngraded
+			price = 0
+		}
+
+		// The toys in the bucket count as held, as they do when more are
+		// added, so that a downgrade can't leave the bucket over the limits.
+		err = checkHoldings(ctx, tx, userID, plan, bucket)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		next := time.Now().Add(plan.BillingPeriod())
+		paidUntil = &next
+	}
+
+	if price > 0 {
+		subscription.Charge = &LedgerEntry{
+			UserID:         userID,
+			Kind:           LedgerCharge,
+			Amount:         -price,
+			Description:    fmt.Sprintf("%s plan (%s)", plan.Name, subscription.Change),
+			IdempotencyKey: idempotencyKey,
+		}
+
+		_, err = postEntry(ctx, tx, subscription.Charge, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+UPDATE users SET plan_id = $1, paid_until = $2, version = version + 1
+WHERE id = $3
+RETURNING paid_until`, plan.ID, paidUntil, userID).Scan(&subscription.PaidUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ledgerEntryID *int64
+	if subscription.Charge != nil {
+		ledgerEntryID = &subscription.Charge.ID
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO plan_changes (user_id, plan_id, change, paid_until, ledger_entry_id, idempotency_key)
+VALUES ($1, $2, $3, $4, $5, $6)`, userID, plan.ID, subscription.Change, subscription.PaidUntil, ledgerEntryID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// getPlanChange returns the subscription change recorded under an
+// idempotency key, with its charge if it had one.
+func getPlanChange(ctx context.Context, tx *sql.Tx, userID int64, idempotencyKey string) (*Subscription, error) {
+	query := `
+SELECT plan_changes.plan_id, plan_changes.change, plan_changes.paid_until, ledger_entries.id, ledger_entries.created_at,
+	ledger_entries.kind, ledger_entries.amount, ledger_entries.balance_after, ledger_entries.description
+FROM plan_changes
+LEFT JOIN ledger_entries ON ledger_entries.id = plan_changes.ledger_entry_id
+WHERE plan_changes.user_id = $1 AND plan_changes.idempotency_key = $2`
+
+	var (
+		planID       int64
+		subscription Subscription
+		chargeID     sql.NullInt64
+		createdAt    sql.NullTime
+		kind         sql.NullString
+		amount       sql.NullInt64
+		balanceAfter sql.NullInt64
+		description  sql.NullString
+	)
+
+	err := tx.QueryRowContext(ctx, query, userID, idempotencyKey).Scan(
+		&planID,
+		&subscription.Change,
+		&subscription.PaidUntil,
+		&chargeID,
+		&createdAt,
+		&kind,
+		&amount,
+		&balanceAfter,
+		&description,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	subscription.Plan, err = getPlan(ctx, tx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chargeID.Valid {
+		subscription.Charge = &LedgerEntry{
+			ID:             chargeID.Int64,
+			CreatedAt:      createdAt.Time,
+			UserID:         userID,
+			Kind:           kind.String,
+			Amount:         amount.Int64,
+			BalanceAfter:   balanceAfter.Int64,
+			Description:    description.String,
+			IdempotencyKey: idempotencyKey,
+		}
+	}
+
+	return &subscription, nil
+}
+
+// checkPlanLimits locks the user's row and verifies that their plan is paid
+// for and lets them hold the given extra toys on top of their open loans.
+// It must be called inside the transaction that reserves the toys.
+func checkPlanLimits(ctx context.Context, tx *sql.Tx, userID int64, extraToyIDs []int64) error {
+	var (
+		planID    sql.NullInt64
+		paidUntil *time.Time
+	)
+
+	err := tx.QueryRowContext(ctx, `SELECT plan_id, paid_until FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&planID, &paidUntil)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrUnknownUser
+		default:
+			return err
+		}
+	}
+
+	if !planID.Valid || paidUntil == nil || paidUntil.Before(time.Now()) {
+		return ErrNoActivePlan
+	}
+
+	plan, err := getPlan(ctx, tx, planID.Int64)
+	if err != nil {
+		return err
+	}
+
+	return checkHoldings(ctx, tx, userID, plan, extraToyIDs)
+}
+
+func checkHoldings(ctx context.Context, tx *sql.Tx, userID int64, plan *Plan, extraToyIDs []int64) error {
+	query := `
+SELECT count(*), COALESCE(sum(toys.value), 0)
+FROM (
+	SELECT toy_id FROM loans WHERE user_id = $1 AND returned_at IS NULL
+	UNION ALL
+	SELECT unnest($2::bigint[])
+) AS held (toy_id)
+INNER JOIN toys ON toys.id = held.toy_id`
+
+	var (
+		count int
+		value int64
+	)
+
+	err := tx.QueryRowContext(ctx, query, userID, pq.Array(extraToyIDs)).Scan(&count, &value)
+	if err != nil {
+		return err
+	}
+
+	if count > plan.MaxToys || value > plan.MaxValue {
+		return ErrPlanLimitExceeded
+	}
+	return nil
+}
@@ -13,6 +13,14 @@ var ErrInvalidRatingFormat = errors.New("Invalid rating format")
 
 var maxRating = 5
 
+// RatingStats sums up the ratings a toy got in its comments. Histogram holds
+// the number of 1 to 5 star ratings, in that order.
+type RatingStats struct {
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+	Histogram []int64 `json:"histogram"`
+}
+
 func (r Rating) MarshalJSON() ([]byte, error) {
 	jsonValue := fmt.Sprintf("%d из %d", r, maxRating)
 
@@ -29,17 +37,12 @@ func (r *Rating) UnmarshalJSON(jsonValue []byte) error {
 
 	parts := strings.Split(unquotedJSONValue, " ")
 
-	ratingValue, err := strconv.Atoi(parts[0])
-	if err != nil {
-		panic(err)
-	}
-
-	if len(parts) != 3 || parts[1] != "из" || ratingValue > maxRating {
+	if len(parts) != 3 || parts[1] != "из" {
 		return ErrInvalidRatingFormat
 	}
 
 	i, err := strconv.ParseInt(parts[0], 10, 32)
-	if err != nil {
+	if err != nil || i > int64(maxRating) {
 		return ErrInvalidRatingFormat
 	}
 
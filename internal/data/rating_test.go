@@ -0,0 +1,62 @@
+package data
+
+import (
+	"errors"
+	"oynas/internal/validator"
+	"testing"
+)
+
+func TestRatingUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Rating
+		err   error
+	}{
+		{`"4 из 5"`, 4, nil},
+		{`"5 из 5"`, 5, nil},
+		{`"6 из 5"`, 0, ErrInvalidRatingFormat},
+		{`"four из 5"`, 0, ErrInvalidRatingFormat},
+		{`"из"`, 0, ErrInvalidRatingFormat},
+		{`""`, 0, ErrInvalidRatingFormat},
+		{`"4 of 5"`, 0, ErrInvalidRatingFormat},
+		{`4`, 0, ErrInvalidRatingFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Rating
+
+			err := got.UnmarshalJSON([]byte(tt.input))
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v; want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommentRating(t *testing.T) {
+	tests := []struct {
+		rating Rating
+		valid  bool
+	}{
+		{0, false},
+		{1, true},
+		{5, true},
+		{6, false},
+		{-1, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+
+		ValidateComment(v, &Comment{Text: "Great toy", Rating: tt.rating})
+
+		if v.Valid() != tt.valid {
+			t.Errorf("got valid %v for rating %d; want %v", v.Valid(), tt.rating, tt.valid)
+		}
+	}
+}
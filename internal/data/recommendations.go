@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"time"
+)
+
+// GetRentedBy returns every toy the user has ever had on loan.
+func (t ToyModel) GetRentedBy(userID int64) ([]*Toy, error) {
+	query := `SELECT ` + toyDetailColumns + `
+FROM toys
+WHERE toys.id IN (SELECT toy_id FROM loans WHERE user_id = $1)
+ORDER BY toys.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toys := []*Toy{}
+
+	for rows.Next() {
+		toy, err := scanToyDetails(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		toys = append(toys, toy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toys, nil
+}
+
+// GetNotRentedBy returns the id, skills, categories and age range of
+// every toy the user has never had on loan.
+func (t ToyModel) GetNotRentedBy(userID int64) ([]*Toy, error) {
+	query := `
+SELECT id, skills, categories, min_age_months, COALESCE(max_age_months, 0)
+FROM toys
+WHERE id NOT IN (SELECT toy_id FROM loans WHERE user_id = $1)
+AND archived_at IS NULL
+ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toys := []*Toy{}
+
+	for rows.Next() {
+		var toy Toy
+
+		err := rows.Scan(
+			&toy.ID,
+			pq.Array(&toy.Skills),
+			pq.Array(&toy.Categories),
+			&toy.RecommendedAge.Min,
+			&toy.RecommendedAge.Max,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		toys = append(toys, &toy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toys, nil
+}
+
+// GetByIDs returns the toys with the given ids in the order of ids. Ids of
+// toys that don't exist are skipped.
+func (t ToyModel) GetByIDs(ids []int64) ([]*Toy, error) {
+	query := `SELECT ` + toyDetailColumns + `
+FROM toys
+WHERE toys.id = ANY($1)
+ORDER BY array_position($1, toys.id)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toys := []*Toy{}
+
+	for rows.Next() {
+		toy, err := scanToyDetails(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		toys = append(toys, toy)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toys, nil
+}
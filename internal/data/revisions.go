@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FieldChange is the value of a toy's field before and after a change, as
+// JSON. Fields that were empty are null.
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// ToyRevision is one change in the history of a toy. Revisions are recorded
+// by a trigger on toys, so every change is there, including the ones made by
+// imports and by renaming manufacturers and taxonomy terms.
+type ToyRevision struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	ToyID     int64                  `json:"toy_id"`
+	UserID    int64                  `json:"user_id,omitempty"`
+	UserName  string                 `json:"user_name,omitempty"`
+	Action    string                 `json:"action"`
+	Changes   map[string]FieldChange `json:"changes"`
+	snapshot  []byte
+}
+
+// toySnapshot is the state of a toy recorded with a revision.
+type toySnapshot struct {
+	SKU            string   `json:"sku"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Details        []string `json:"details"`
+	Skills         []string `json:"skills"`
+	Categories     []string `json:"categories"`
+	Images         []string `json:"images"`
+	MinAgeMonths   int      `json:"min_age_months"`
+	MaxAgeMonths   int      `json:"max_age_months"`
+	ManufacturerID int64    `json:"manufacturer_id"`
+	Value          int64    `json:"value"`
+}
+
+// Apply sets the fields of the toy to the ones it had at the revision, or
+// right before it for a delete. The toy keeps its id and version, so it can
+// be saved with ToyModel.Update to roll it back.
+func (r *ToyRevision) Apply(toy *Toy) error {
+	var snapshot toySnapshot
+
+	err := json.Unmarshal(r.snapshot, &snapshot)
+	if err != nil {
+		return fmt.Errorf("revision %d: %w", r.ID, err)
+	}
+
+	toy.SKU = snapshot.SKU
+	toy.Title = snapshot.Title
+	toy.Description = snapshot.Description
+	toy.Details = snapshot.Details
+	toy.Skills = snapshot.Skills
+	toy.Categories = snapshot.Categories
+	toy.Images = snapshot.Images
+	toy.RecommendedAge = AgeRange{Min: snapshot.MinAgeMonths, Max: snapshot.MaxAgeMonths}
+	toy.ManufacturerID = snapshot.ManufacturerID
+	toy.Value = snapshot.Value
+
+	return nil
+}
+
+type ToyRevisionModel struct {
+	DB *sql.DB
+}
+
+// beginAs starts a transaction whose changes to toys are recorded in their
+// history as made by the user, or by the system when userID is 0.
+func beginAs(ctx context.Context, db *sql.DB, userID int64) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	setting := ""
+	if userID != 0 {
+		setting = strconv.FormatInt(userID, 10)
+	}
+
+	_, err = tx.ExecContext(ctx, `SELECT set_config('oynas.user_id', $1, true)`, setting)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+const toyRevisionColumns = `toy_revisions.id, toy_revisions.created_at, toy_revisions.toy_id, COALESCE(toy_revisions.user_id, 0),
+	COALESCE(users.name, ''), toy_revisions.action, toy_revisions.changes, toy_revisions.snapshot`
+
+// scanToyRevision reads a row of toyRevisionColumns, after the columns read
+// into dest.
+func scanToyRevision(row rowScanner, dest ...any) (*ToyRevision, error) {
+	var revision ToyRevision
+	var changes []byte
+
+	dest = append(dest,
+		&revision.ID,
+		&revision.CreatedAt,
+		&revision.ToyID,
+		&revision.UserID,
+		&revision.UserName,
+		&revision.Action,
+		&changes,
+		&revision.snapshot,
+	)
+
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(changes, &revision.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// Get returns one revision of a toy.
+func (m ToyRevisionModel) Get(toyID, id int64) (*ToyRevision, error) {
+	if toyID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + toyRevisionColumns + `
+FROM toy_revisions
+LEFT JOIN users ON users.id = toy_revisions.user_id
+WHERE toy_revisions.toy_id = $1 AND toy_revisions.id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	revision, err := scanToyRevision(m.DB.QueryRowContext(ctx, query, toyID, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+// GetAllForToy lists the history of a toy.
+func (m ToyRevisionModel) GetAllForToy(toyID int64, filters Filters) ([]*ToyRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), `+toyRevisionColumns+`
+FROM toy_revisions
+LEFT JOIN users ON users.id = toy_revisions.user_id
+WHERE toy_revisions.toy_id = $1
+ORDER BY toy_revisions.%s %s
+LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, toyID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*ToyRevision{}
+
+	for rows.Next() {
+		revision, err := scanToyRevision(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
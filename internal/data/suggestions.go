@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Suggestions are completions of a partly typed search query, best first.
+type Suggestions struct {
+	Titles        []string `json:"titles"`
+	Manufacturers []string `json:"manufacturers"`
+	Categories    []string `json:"categories"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest completes a search query with up to limit toy titles, manufacturers
+// and categories each. Values starting with the query come first, then the
+// ones most similar to it by trigrams, so that misspelled queries still get
+// completions.
+func (t ToyModel) Suggest(prefix string, limit int) (*Suggestions, error) {
+	query := `
+WITH candidates AS (
+	SELECT 'title' AS kind, title AS value
+	FROM toys
+	WHERE (title ILIKE $2 OR $1 <% title) AND archived_at IS NULL
+	UNION
+	SELECT 'manufacturer', name
+	FROM manufacturers
+	WHERE name ILIKE $2 OR $1 <% name
+	UNION
+	SELECT 'category', category
+	FROM (SELECT DISTINCT unnest(categories) AS category FROM toys WHERE archived_at IS NULL) AS c
+	WHERE category ILIKE $2 OR $1 <% category
+), ranked AS (
+	SELECT kind, value, row_number() OVER (
+		PARTITION BY kind
+		ORDER BY value ILIKE $2 DESC, word_similarity($1, value) DESC, length(value), value
+	) AS position
+	FROM candidates
+)
+SELECT kind, value
+FROM ranked
+WHERE position <= $3
+ORDER BY kind, position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, prefix, likeEscaper.Replace(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := Suggestions{
+		Titles:        []string{},
+		Manufacturers: []string{},
+		Categories:    []string{},
+	}
+
+	for rows.Next() {
+		var kind, value string
+
+		err := rows.Scan(&kind, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case "title":
+			suggestions.Titles = append(suggestions.Titles, value)
+		case "manufacturer":
+			suggestions.Manufacturers = append(suggestions.Manufacturers, value)
+		case "category":
+			suggestions.Categories = append(suggestions.Categories, value)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &suggestions, nil
+}
@@ -0,0 +1,397 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"regexp"
+	"time"
+)
+
+const (
+	VocabularyCategory = "category"
+	VocabularySkill    = "skill"
+)
+
+var (
+	ErrDuplicateSlug = errors.New("duplicate slug")
+	ErrUnknownParent = errors.New("unknown parent term")
+	ErrTermCycle     = errors.New("term would be its own ancestor")
+	ErrTermInUse     = errors.New("term is in use")
+)
+
+// SlugRX matches lowercase words of letters and digits joined by dashes, in
+// any script.
+var SlugRX = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{Nd}]+(-[\p{Ll}\p{Lo}\p{Nd}]+)*$`)
+
+// TaxonomyTerm is a category or skill toys can be tagged with. Toys store the
+// slugs of their terms, and a term stands for all the terms below it when
+// toys are filtered.
+type TaxonomyTerm struct {
+	ID         int64             `json:"id"`
+	CreatedAt  time.Time         `json:"-"`
+	Vocabulary string            `json:"vocabulary"`
+	Slug       string            `json:"slug"`
+	ParentID   int64             `json:"parent_id,omitempty"`
+	Labels     map[string]string `json:"labels"`
+	Version    int               `json:"version"`
+}
+
+func ValidateTaxonomyTerm(v *validator.Validator, term *TaxonomyTerm) {
+	v.Check(term.Slug != "", "slug", "must be provided")
+	v.Check(len(term.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	v.Check(term.Slug == "" || validator.Matches(term.Slug, SlugRX), "slug", "must be lowercase words joined by dashes")
+	v.Check(term.ParentID >= 0, "parent_id", "must not be negative")
+	v.Check(term.ParentID == 0 || term.ParentID != term.ID, "parent_id", "must not be the term itself")
+	v.Check(term.Labels != nil, "labels", "must be provided")
+
+	for language, label := range term.Labels {
+		v.Check(validator.PermittedValue(language, SearchRussian, SearchKazakh, SearchEnglish), "labels", "must only have ru, kk and en labels")
+		v.Check(label != "", "labels", "must not contain empty labels")
+		v.Check(len(label) <= 100, "labels", "must not contain labels longer than 100 bytes")
+	}
+}
+
+// Taxonomy is the set of known slugs of each vocabulary.
+type Taxonomy struct {
+	Categories map[string]bool
+	Skills     map[string]bool
+}
+
+type TaxonomyModel struct {
+	DB *sql.DB
+}
+
+// Load reads the slugs of every term, for validating toys.
+func (m TaxonomyModel) Load() (*Taxonomy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT vocabulary, slug FROM taxonomy_terms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taxonomy := Taxonomy{
+		Categories: map[string]bool{},
+		Skills:     map[string]bool{},
+	}
+
+	for rows.Next() {
+		var vocabulary, slug string
+
+		err := rows.Scan(&vocabulary, &slug)
+		if err != nil {
+			return nil, err
+		}
+
+		switch vocabulary {
+		case VocabularyCategory:
+			taxonomy.Categories[slug] = true
+		case VocabularySkill:
+			taxonomy.Skills[slug] = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &taxonomy, nil
+}
+
+func (m TaxonomyModel) Insert(term *TaxonomyTerm) error {
+	labels, err := json.Marshal(term.Labels)
+	if err != nil {
+		return err
+	}
+
+	query := `
+INSERT INTO taxonomy_terms (vocabulary, slug, parent_id, labels)
+VALUES ($1, $2, NULLIF($3, 0), $4)
+RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, term.Vocabulary, term.Slug, term.ParentID, labels).Scan(&term.ID, &term.CreatedAt, &term.Version)
+	if err != nil {
+		return taxonomyError(err)
+	}
+	return nil
+}
+
+// Get returns a term of the vocabulary. Terms of other vocabularies are
+// reported as not found.
+func (m TaxonomyModel) Get(vocabulary string, id int64) (*TaxonomyTerm, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, vocabulary, slug, COALESCE(parent_id, 0), labels, version
+FROM taxonomy_terms
+WHERE vocabulary = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	term, err := scanTaxonomyTerm(m.DB.QueryRowContext(ctx, query, vocabulary, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return term, nil
+}
+
+// GetAll lists the terms of a vocabulary, each parent before its children.
+func (m TaxonomyModel) GetAll(vocabulary string) ([]*TaxonomyTerm, error) {
+	query := `
+WITH RECURSIVE tree AS (
+	SELECT id, ARRAY[slug] AS path
+	FROM taxonomy_terms
+	WHERE vocabulary = $1 AND parent_id IS NULL
+	UNION ALL
+	SELECT taxonomy_terms.id, tree.path || taxonomy_terms.slug
+	FROM taxonomy_terms
+	INNER JOIN tree ON taxonomy_terms.parent_id = tree.id
+)
+SELECT taxonomy_terms.id, taxonomy_terms.created_at, taxonomy_terms.vocabulary, taxonomy_terms.slug,
+	COALESCE(taxonomy_terms.parent_id, 0), taxonomy_terms.labels, taxonomy_terms.version
+FROM taxonomy_terms
+INNER JOIN tree ON tree.id = taxonomy_terms.id
+ORDER BY tree.path`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := []*TaxonomyTerm{}
+
+	for rows.Next() {
+		term, err := scanTaxonomyTerm(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		terms = append(terms, term)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+// Update saves a term. Moving a term below one of its own descendants fails
+// with ErrTermCycle, and renaming its slug renames it on every toy, and on
+// children's interests for skills.
+func (m TaxonomyModel) Update(term *TaxonomyTerm) error {
+	labels, err := json.Marshal(term.Labels)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if term.ParentID != 0 {
+		var cycle bool
+		err = tx.QueryRowContext(ctx, `
+WITH RECURSIVE ancestors AS (
+	SELECT id, parent_id FROM taxonomy_terms WHERE id = $1
+	UNION
+	SELECT taxonomy_terms.id, taxonomy_terms.parent_id
+	FROM taxonomy_terms
+	INNER JOIN ancestors ON taxonomy_terms.id = ancestors.parent_id
+)
+SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`, term.ParentID, term.ID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTermCycle
+		}
+	}
+
+	var oldSlug string
+	err = tx.QueryRowContext(ctx, `SELECT slug FROM taxonomy_terms WHERE id = $1 FOR UPDATE`, term.ID).Scan(&oldSlug)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	query := `
+UPDATE taxonomy_terms
+SET slug = $1, parent_id = NULLIF($2, 0), labels = $3, version = version + 1
+WHERE id = $4 AND version = $5
+RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, term.Slug, term.ParentID, labels, term.ID, term.Version).Scan(&term.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return taxonomyError(err)
+		}
+	}
+
+	if oldSlug != term.Slug {
+		column := "categories"
+		if term.Vocabulary == VocabularySkill {
+			column = "skills"
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+UPDATE toys SET %[1]s = array_replace(%[1]s, $1, $2), version = version + 1
+WHERE $1 = ANY(%[1]s)`, column), oldSlug, term.Slug)
+		if err != nil {
+			return err
+		}
+
+		if term.Vocabulary == VocabularyCategory {
+			_, err = tx.ExecContext(ctx, `
+UPDATE users SET favorite_categories = array_replace(favorite_categories, $1, $2)
+WHERE $1 = ANY(favorite_categories)`, oldSlug, term.Slug)
+			if err != nil {
+				return err
+			}
+		}
+
+		if term.Vocabulary == VocabularySkill {
+			_, err = tx.ExecContext(ctx, `
+UPDATE children SET interests = array_replace(interests, $1, $2), version = version + 1
+WHERE $1 = ANY(interests)`, oldSlug, term.Slug)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a term that has no children and no toys tagged with it. A
+// deleted category is dropped from the users who picked it as a favorite.
+func (m TaxonomyModel) Delete(vocabulary string, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var slug string
+	err = tx.QueryRowContext(ctx, `
+SELECT slug FROM taxonomy_terms
+WHERE vocabulary = $1 AND id = $2
+FOR UPDATE`, vocabulary, id).Scan(&slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	var inUse bool
+	err = tx.QueryRowContext(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM toys
+	WHERE ($1 = 'category' AND $2 = ANY(categories)) OR ($1 = 'skill' AND $2 = ANY(skills))
+) OR EXISTS(SELECT 1 FROM taxonomy_terms WHERE parent_id = $3)`, vocabulary, slug, id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrTermInUse
+	}
+
+	if vocabulary == VocabularyCategory {
+		_, err = tx.ExecContext(ctx, `
+UPDATE users SET favorite_categories = array_remove(favorite_categories, $1)
+WHERE $1 = ANY(favorite_categories)`, slug)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM taxonomy_terms WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanTaxonomyTerm(row rowScanner) (*TaxonomyTerm, error) {
+	var term TaxonomyTerm
+	var labels []byte
+
+	err := row.Scan(
+		&term.ID,
+		&term.CreatedAt,
+		&term.Vocabulary,
+		&term.Slug,
+		&term.ParentID,
+		&labels,
+		&term.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(labels, &term.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &term, nil
+}
+
+func taxonomyError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "taxonomy_terms_vocabulary_slug_key":
+			return ErrDuplicateSlug
+		case "taxonomy_terms_parent_fkey":
+			return ErrUnknownParent
+		}
+	}
+	return err
+}
@@ -3,42 +3,133 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
 	"oynas/internal/validator"
+	"strings"
 	"time"
 )
 
 var AnonymousUser = &User{}
 
+var ErrDuplicateSKU = errors.New("duplicate sku")
+
+// toyUnitsAvailable is the number of a toy's in-stock units that are not held
+// for somebody at the front of its wait list.
+const toyUnitsAvailable = `GREATEST(
+(SELECT count(*) FROM toy_units WHERE toy_units.toy_id = toys.id AND toy_units.status = 'in_stock')
+- (SELECT count(*) FROM wait_list WHERE wait_list.toy_id = toys.id AND wait_list.status = 'offered' AND wait_list.hold_expiry > now()),
+0)`
+
+const toyUnitsTotal = `(SELECT count(*) FROM toy_units WHERE toy_units.toy_id = toys.id AND toy_units.status <> 'retired')`
+
+const toyWaitListSize = `(SELECT count(*) FROM wait_list WHERE wait_list.toy_id = toys.id AND wait_list.status IN ('waiting', 'offered'))`
+
+// toyDetailColumns is the column list read by scanToyDetails.
+const toyDetailColumns = `toys.id, toys.created_at, toys.title, toys.description, toys.details, toys.skills, toys.categories, toys.images,
+toys.min_age_months, COALESCE(toys.max_age_months, 0), COALESCE(toys.manufacturer_id, 0), COALESCE(toys.manufacturer, ''), toys.value, COALESCE(toys.sku, ''), toys.version, toys.archived_at,
+toys.rating_average, toys.rating_count, toys.rating_histogram, toys.loan_count, toys.image_thumbnails,
+` + toyUnitsAvailable + `, ` + toyUnitsTotal + `, ` + toyWaitListSize
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToyDetails(row rowScanner) (*Toy, error) {
+	var toy Toy
+	var manufacturerName string
+	var thumbnails []byte
+
+	err := row.Scan(
+		&toy.ID,
+		&toy.CreatedAt,
+		&toy.Title,
+		&toy.Description,
+		pq.Array(&toy.Details),
+		pq.Array(&toy.Skills),
+		pq.Array(&toy.Categories),
+		pq.Array(&toy.Images),
+		&toy.RecommendedAge.Min,
+		&toy.RecommendedAge.Max,
+		&toy.ManufacturerID,
+		&manufacturerName,
+		&toy.Value,
+		&toy.SKU,
+		&toy.Version,
+		&toy.ArchivedAt,
+		&toy.Rating.Average,
+		&toy.Rating.Count,
+		pq.Array(&toy.Rating.Histogram),
+		&toy.Popularity,
+		&thumbnails,
+		&toy.UnitsAvailable,
+		&toy.UnitsTotal,
+		&toy.WaitListSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(thumbnails, &toy.Thumbnails)
+	if err != nil {
+		return nil, err
+	}
+
+	toy.IsAvailable = toy.UnitsAvailable > 0
+	toy.setManufacturer(manufacturerName)
+
+	return &toy, nil
+}
+
+// MaxToyImages is the number of images a toy can have.
+const MaxToyImages = 20
+
 type Toy struct {
-	ID             int64     `json:"id"`
-	CreatedAt      time.Time `json:"-"`
-	Title          string    `json:"title"`
-	Description    string    `json:"desc"`
-	Details        []string  `json:"details,omitempty"`
-	Skills         []string  `json:"skills"`
-	Images         []string  `json:"image"`
-	Categories     []string  `json:"categories"`
-	RecommendedAge string    `json:"recommended_age"`
-	Manufacturer   string    `json:"manufacturer"`
-	Value          int64     `json:"value"`
-	IsAvailable    string    `json:"isAvailable"`
-	WaitList       []string  `json:"waitList,omitempty"`
-	Comments       []Comment `json:"-"`
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	SKU         string    `json:"sku,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"desc"`
+	Details     []string  `json:"details,omitempty"`
+	Skills      []string  `json:"skills"`
+	Images      []string  `json:"image"`
+	// Thumbnails holds the thumbnail URLs of uploaded images by size name,
+	// keyed by the image's URL.
+	Thumbnails     map[string]map[string]string `json:"thumbnails,omitempty"`
+	Categories     []string                     `json:"categories"`
+	RecommendedAge AgeRange                     `json:"recommended_age"`
+	ManufacturerID int64                        `json:"manufacturer_id"`
+	Manufacturer   *Manufacturer                `json:"manufacturer,omitempty"`
+	Value          int64                        `json:"value"`
+	IsAvailable    bool                         `json:"isAvailable"`
+	UnitsAvailable int                          `json:"units_available"`
+	UnitsTotal     int                          `json:"units_total"`
+	WaitListSize   int                          `json:"wait_list_size"`
+	Rating         RatingStats                  `json:"rating"`
+	Popularity     int                          `json:"popularity"`
+	Relevance      float64                      `json:"relevance,omitempty"`
+	Snippet        string                       `json:"snippet,omitempty"`
+	Version        int                          `json:"version,omitempty"`
+	ArchivedAt     *time.Time                   `json:"archived_at,omitempty"`
+	Comments       []Comment                    `json:"-"`
 }
 
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
-func ValidateToy(v *validator.Validator, toy *Toy) {
+// ValidateToy checks a toy, including that its categories and skills are
+// terms of the taxonomy.
+func ValidateToy(v *validator.Validator, toy *Toy, taxonomy *Taxonomy) {
+	v.Check(len(toy.SKU) <= 100, "sku", "sku must not be more than 100 bytes long")
 	v.Check(toy.Title != "", "title", "title must be provided")
 	v.Check(len(toy.Title) <= 500, "title", "title must not be more than 500 bytes long")
 	v.Check(len(toy.Description) <= 5000, "desc", "Description must not be more than 5000 bytes long")
 	v.Check(len(toy.Details) <= 5, "details", "details must not be more than 5")
 	v.Check(v.ImageUrlsCheck(toy.Images), "image", "some of image urls is wrong")
+	v.Check(len(toy.Images) <= MaxToyImages, "image", fmt.Sprintf("images must not be more than %d", MaxToyImages))
 	v.Check(toy.Categories != nil, "categories", "categories must be provided")
 	v.Check(toy.Skills != nil, "skills", "skills must be provided")
 	v.Check(len(toy.Categories) >= 1, "categories", "at least 1 category")
@@ -47,8 +138,16 @@ func ValidateToy(v *validator.Validator, toy *Toy) {
 	v.Check(len(toy.Skills) <= 7, "Skills", "no more than 7 skills")
 	v.Check(validator.Unique(toy.Categories), "categories", "categories should not contain duplicate values")
 	v.Check(validator.Unique(toy.Skills), "skills", "skills should not contain duplicate values")
-	v.Check(toy.RecommendedAge != "", "recAge", "age must be provided")
-	v.Check(toy.Manufacturer != "", "manufacturer", "manufacturer must be provided")
+	for _, category := range toy.Categories {
+		v.Check(taxonomy.Categories[category], "categories", fmt.Sprintf("%q is not a known category", category))
+	}
+	for _, skill := range toy.Skills {
+		v.Check(taxonomy.Skills[skill], "skills", fmt.Sprintf("%q is not a known skill", skill))
+	}
+	v.Check(toy.RecommendedAge.Min <= 18*12, "recAge", "minimum age must not be more than 18 years")
+	v.Check(toy.RecommendedAge.Max < 19*12, "recAge", "maximum age must not be more than 18 years")
+	v.Check(toy.RecommendedAge.Max == 0 || toy.RecommendedAge.Max >= toy.RecommendedAge.Min, "recAge", "maximum age must not be less than minimum age")
+	v.Check(toy.ManufacturerID > 0, "manufacturer_id", "manufacturer must be provided")
 	v.Check(toy.Value >= 1000, "value", "toy value must be more than 1000 tenge")
 	v.Check(toy.Value <= 150000, "value", "limit of toy's value is 150.000 tenge")
 }
@@ -57,17 +156,46 @@ type ToyModel struct {
 	DB *sql.DB
 }
 
-func (t ToyModel) Insert(toy *Toy) error {
+// setManufacturer embeds the toy's manufacturer, given its name.
+func (toy *Toy) setManufacturer(name string) {
+	toy.Manufacturer = nil
+	if toy.ManufacturerID != 0 {
+		toy.Manufacturer = &Manufacturer{ID: toy.ManufacturerID, Name: name}
+	}
+}
+
+// Insert adds a toy, recording the user who added it in its history.
+func (t ToyModel) Insert(toy *Toy, userID int64) error {
 	query := `
-INSERT INTO toys (title, description, details, skills, categories, images, recommended_age, manufacturer, value, is_available, wait_list)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-RETURNING id, created_at`
+INSERT INTO toys (title, description, details, skills, categories, images, min_age_months, max_age_months, manufacturer_id, value, sku)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10, NULLIF($11, ''))
+RETURNING id, created_at, manufacturer, sku, version`
 
-	args := []any{toy.Title, toy.Description, pq.Array(toy.Details), pq.Array(toy.Skills), pq.Array(toy.Categories), pq.Array(toy.Images), toy.RecommendedAge, toy.Manufacturer, toy.Value, toy.IsAvailable, pq.Array(toy.WaitList)}
+	args := []any{toy.Title, toy.Description, pq.Array(toy.Details), pq.Array(toy.Skills), pq.Array(toy.Categories), pq.Array(toy.Images), toy.RecommendedAge.Min, toy.RecommendedAge.Max, toy.ManufacturerID, toy.Value, toy.SKU}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return t.DB.QueryRowContext(ctx, query, args...).Scan(&toy.ID, &toy.CreatedAt)
+
+	tx, err := beginAs(ctx, t.DB, userID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var manufacturerName string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&toy.ID, &toy.CreatedAt, &manufacturerName, &toy.SKU, &toy.Version)
+	if err != nil {
+		return toyError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	toy.setManufacturer(manufacturerName)
+	return nil
 }
 
 func (t ToyModel) Get(id int64) (*Toy, error) {
@@ -75,33 +203,15 @@ func (t ToyModel) Get(id int64) (*Toy, error) {
 		return nil, ErrRecordNotFound
 	}
 
-	query := `
-SELECT id, created_at, title, description, details ,skills, categories, images, recommended_age, manufacturer, value, is_available, wait_list
+	query := `SELECT ` + toyDetailColumns + `
 FROM toys
 WHERE id = $1
 `
 
-	var toy Toy
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := t.DB.QueryRowContext(ctx, query, id).Scan(
-		&toy.ID,
-		&toy.CreatedAt,
-		&toy.Title,
-		&toy.Description,
-		pq.Array(&toy.Details),
-		pq.Array(&toy.Skills),
-		pq.Array(&toy.Categories),
-		pq.Array(&toy.Images),
-		&toy.RecommendedAge,
-		&toy.Manufacturer,
-		&toy.Value,
-		&toy.IsAvailable,
-		pq.Array(&toy.WaitList),
-	)
-
+	toy, err := scanToyDetails(t.DB.QueryRowContext(ctx, query, id))
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -111,15 +221,31 @@ WHERE id = $1
 		}
 	}
 
-	return &toy, nil
+	return toy, nil
 }
 
-func (t ToyModel) Update(toy *Toy) error {
+// Update saves a toy if its version is still the one it was read with, and
+// returns ErrEditConflict otherwise. The user who saved it is recorded in its
+// history.
+func (t ToyModel) Update(toy *Toy, userID int64) error {
+	// Thumbnails of images the toy no longer has are dropped.
+	thumbnails := map[string]map[string]string{}
+	for _, image := range toy.Images {
+		if sizes, ok := toy.Thumbnails[image]; ok {
+			thumbnails[image] = sizes
+		}
+	}
+	toy.Thumbnails = thumbnails
+
+	thumbnailsJSON, err := json.Marshal(thumbnails)
+	if err != nil {
+		return err
+	}
 
 	query := `UPDATE toys
-SET title = $1, description = $2, details = $3, skills = $4, images = $5, categories = $6, recommended_age = $7, manufacturer = $8, value = $9
-WHERE id = $10
-RETURNING id
+SET title = $1, description = $2, details = $3, skills = $4, images = $5, categories = $6, min_age_months = $7, max_age_months = NULLIF($8, 0), manufacturer_id = $9, value = $10, sku = NULLIF($11, ''), image_thumbnails = $14, version = version + 1
+WHERE id = $12 AND version = $13
+RETURNING manufacturer, sku, version
 `
 	args := []any{
 		toy.Title,
@@ -128,45 +254,72 @@ RETURNING id
 		pq.Array(toy.Skills),
 		pq.Array(toy.Images),
 		pq.Array(toy.Categories),
-		toy.RecommendedAge,
-		toy.Manufacturer,
+		toy.RecommendedAge.Min,
+		toy.RecommendedAge.Max,
+		toy.ManufacturerID,
 		toy.Value,
+		toy.SKU,
 		toy.ID,
+		toy.Version,
+		thumbnailsJSON,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := t.DB.QueryRowContext(ctx, query, args...).Scan(&toy.ID)
+	tx, err := beginAs(ctx, t.DB, userID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var manufacturerName string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&manufacturerName, &toy.SKU, &toy.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return toyError(err)
 		}
 
 	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	toy.setManufacturer(manufacturerName)
 	return nil
 
 }
 
-func (t ToyModel) Delete(id int64) error {
-
+// Archive hides a toy from the catalog instead of deleting it, so that its
+// comments and loan history are kept until it is purged. Archiving a toy that
+// is already archived returns ErrRecordNotFound.
+func (t ToyModel) Archive(id, userID int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-DELETE FROM toys
-WHERE id = $1
+UPDATE toys
+SET archived_at = now(), version = version + 1
+WHERE id = $1 AND archived_at IS NULL
 `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := t.DB.ExecContext(ctx, query, id)
+	tx, err := beginAs(ctx, t.DB, userID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -179,24 +332,329 @@ WHERE id = $1
 	if rowsAffected == 0 {
 		return ErrRecordNotFound
 	}
-	return nil
 
+	return tx.Commit()
+}
+
+// Restore puts an archived toy back in the catalog. Restoring a toy that
+// isn't archived returns ErrRecordNotFound.
+func (t ToyModel) Restore(id, userID int64) (*Toy, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+UPDATE toys
+SET archived_at = NULL, version = version + 1
+WHERE id = $1 AND archived_at IS NOT NULL
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := beginAs(ctx, t.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Get(id)
+}
+
+// PurgeArchived permanently deletes the toys archived before the cutoff,
+// along with their comments, units and past loans, and returns how many were
+// deleted. Toys still out on loan are kept until they are returned.
+func (t ToyModel) PurgeArchived(before time.Time) (int64, error) {
+	query := `
+DELETE FROM toys
+WHERE archived_at < $1
+AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.toy_id = toys.id AND loans.returned_at IS NULL)
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := t.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+const (
+	SearchRussian = "ru"
+	SearchKazakh  = "kk"
+	SearchEnglish = "en"
+)
+
+// toySearchLanguages maps each search language to its generated tsvector
+// column and text search configuration. Postgres has no Kazakh stemmer, so
+// the kazakh configuration is built on a hunspell dictionary by the
+// migrations.
+var toySearchLanguages = map[string]struct{ column, config string }{
+	SearchRussian: {"search_ru", "russian"},
+	SearchKazakh:  {"search_kk", "kazakh"},
+	SearchEnglish: {"search_en", "english"},
+}
+
+// ToySearch holds the catalog filters of GetAll. Empty strings and slices
+// match every toy.
+type ToySearch struct {
+	// Query is matched against the title, manufacturer, details and
+	// description of toys, in the language given by Language.
+	Query    string
+	Language string
+	// Skills and Categories are slugs of taxonomy terms a toy must all
+	// have, either themselves or one of the terms below them.
+	Skills     []string
+	Categories []string
+	MinValue   int64
+	MaxValue   int64
+	// ManufacturerID limits the search to one manufacturer's toys, unless
+	// it is 0.
+	ManufacturerID int64
+	// Age is a child's age in months. Only toys whose recommended age range
+	// contains it are returned; a negative Age matches every toy.
+	Age int
+	// BoostSkills moves toys with more of these skills to the top of the
+	// results, ahead of the requested sort order.
+	BoostSkills []string
+	// Fuzzy matches Query against titles and manufacturers by trigram
+	// similarity instead of full-text search, so misspelled queries still
+	// find something.
+	Fuzzy bool
+	// IncludeArchived also matches archived toys, which are hidden from the
+	// catalog otherwise.
+	IncludeArchived bool
+	// categoryTerms and skillTerms are Categories and Skills, each with the
+	// terms below it, as read by ToyModel.expandTerms.
+	categoryTerms [][]string
+	skillTerms    [][]string
+}
+
+func ValidateToySearch(v *validator.Validator, search ToySearch) {
+	v.Check(len(search.Query) <= 200, "q", "must not be more than 200 bytes long")
+	v.Check(validator.PermittedValue(search.Language, SearchRussian, SearchKazakh, SearchEnglish), "lang", "must be ru, kk or en")
+	v.Check(search.ManufacturerID >= 0, "manufacturer_id", "must not be negative")
+}
+
+// Stemmed reports whether the query matches other forms of its words, which
+// isn't the case for fuzzy searches or the simple configuration.
+func (s ToySearch) Stemmed() bool {
+	_, config := s.language()
+	return !s.Fuzzy && config != "simple"
+}
+
+func (s ToySearch) language() (column, config string) {
+	language, ok := toySearchLanguages[s.Language]
+	if !ok {
+		language = toySearchLanguages[SearchRussian]
+	}
+	return language.column, language.config
+}
+
+// match is the condition on the search query, which is query parameter $1.
+func (s ToySearch) match() string {
+	if s.Fuzzy {
+		return `($1 <% title OR $1 <% manufacturer OR $1 = '')`
+	}
+
+	column, config := s.language()
+	return fmt.Sprintf(`(%s @@ websearch_to_tsquery('%s', $1) OR $1 = '')`, column, config)
 }
 
-func (t ToyModel) GetAll(title string, skills []string, categories []string, value int64, from int64, to int64, filters Filters) ([]*Toy, Metadata, error) {
-	query := fmt.Sprintf(`SELECT count(*) OVER(), id, title, categories, skills, recommended_age, value from toys 
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-AND (categories @> $2 OR $2 = '{}')
-AND (skills @> $3 OR $3 = '{}')
+// rank scores how well a toy matches the search query.
+func (s ToySearch) rank() string {
+	if s.Fuzzy {
+		return `GREATEST(word_similarity($1, title), word_similarity($1, manufacturer))`
+	}
+
+	column, config := s.language()
+	return fmt.Sprintf(`ts_rank_cd(%s, websearch_to_tsquery('%s', $1))`, column, config)
+}
+
+// conditions filters toys by the search. It takes the first eight query
+// parameters, in the order returned by args, and the search's terms must have
+// been expanded.
+func (s ToySearch) conditions() string {
+	return s.match() + `
+AND ` + termsCondition("categories", "$2", s.categoryTerms) + `
+AND ` + termsCondition("skills", "$3", s.skillTerms) + `
 AND (value BETWEEN $4 and $5)
-ORDER BY %s %s, id ASC
-LIMIT $6 OFFSET $7`, filters.sortColumn(), filters.sortDirection())
+AND ($6 < 0 OR (min_age_months <= $6 AND (max_age_months IS NULL OR max_age_months >= $6)))
+AND ($7 = 0 OR manufacturer_id = $7)
+AND ($8 OR archived_at IS NULL)`
+}
+
+func (s ToySearch) args() []any {
+	return []any{s.Query, pq.Array(flatten(s.categoryTerms)), pq.Array(flatten(s.skillTerms)), s.MinValue, s.MaxValue, s.Age, s.ManufacturerID, s.IncludeArchived}
+}
+
+// termsCondition requires column to overlap every group of terms. param is a
+// text array of all the groups one after the other, and each group is taken
+// from it by a slice, so that the condition can use the column's index.
+func termsCondition(column, param string, groups [][]string) string {
+	// param is used even without groups, so that its type is known.
+	conditions := []string{param + "::text[] IS NOT NULL"}
+
+	low := 1
+	for _, group := range groups {
+		high := low + len(group) - 1
+		conditions = append(conditions, fmt.Sprintf("%s && (%s::text[])[%d:%d]", column, param, low, high))
+		low = high + 1
+	}
+
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+func flatten(groups [][]string) []string {
+	flat := []string{}
+	for _, group := range groups {
+		flat = append(flat, group...)
+	}
+	return flat
+}
+
+// expandTerms reads the terms below each of the search's categories and
+// skills once, instead of once for every toy the search looks at.
+func (t ToyModel) expandTerms(search ToySearch) (ToySearch, error) {
+	query := `
+SELECT taxonomy_descendants($1, wanted.slug)
+FROM unnest($2::text[]) WITH ORDINALITY AS wanted (slug, position)
+ORDER BY wanted.position`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{title, pq.Array(categories), pq.Array(skills), from, to, filters.limit(), filters.offset()}
+	expand := func(vocabulary string, slugs []string) ([][]string, error) {
+		groups := [][]string{}
+		if len(slugs) == 0 {
+			return groups, nil
+		}
+
+		rows, err := t.DB.QueryContext(ctx, query, vocabulary, pq.Array(slugs))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var group []string
+
+			err := rows.Scan(pq.Array(&group))
+			if err != nil {
+				return nil, err
+			}
+
+			groups = append(groups, group)
+		}
+
+		return groups, rows.Err()
+	}
+
+	var err error
+
+	search.categoryTerms, err = expand(VocabularyCategory, search.Categories)
+	if err != nil {
+		return search, err
+	}
+
+	search.skillTerms, err = expand(VocabularySkill, search.Skills)
+	if err != nil {
+		return search, err
+	}
+
+	return search, nil
+}
+
+// GetAll lists the toys matching a search, by page or by cursor. Filters.Sort
+// may be "relevance", which puts the best matches of the search query first,
+// "rating", which sorts by average rating and then by number of ratings, or
+// "popularity", which sorts by the number of times toys were lent out.
+// When there is a query, every toy comes with a snippet of its text with the
+// matches marked, except in fuzzy searches, whose matches can't be marked.
+func (t ToyModel) GetAll(search ToySearch, filters Filters) ([]*Toy, Metadata, error) {
+	search, err := t.expandTerms(search)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	_, config := search.language()
+
+	// The snippet is HTML with the matches in <mark> tags, so the text is
+	// escaped before they are added.
+	snippet := fmt.Sprintf(`ts_headline('%[1]s',
+		replace(replace(replace(concat_ws(' ', description, toys_array_text(details)), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+		websearch_to_tsquery('%[1]s', $1), 'MaxFragments=2, MinWords=5, MaxWords=20, StartSel=<mark>, StopSel=</mark>')`, config)
+	if search.Fuzzy {
+		snippet = `''`
+	}
+
+	// Toys are ordered by the number of boosted skills they have, then by the
+	// requested sort and then by id, which also makes the cursors unique.
+	keys := []sortKey{{expr: `(SELECT count(*) FROM unnest(skills) AS skill WHERE skill = ANY($11))`, desc: true}}
+	switch filters.sortColumn() {
+	case "id":
+	case "relevance":
+		keys = append(keys, sortKey{expr: search.rank(), desc: true})
+	case "rating":
+		desc := filters.sortDirection() == "DESC"
+		keys = append(keys, sortKey{expr: "rating_average", desc: desc}, sortKey{expr: "rating_count", desc: desc})
+	case "popularity":
+		keys = append(keys, sortKey{expr: "loan_count", desc: filters.sortDirection() == "DESC"})
+	default:
+		keys = append(keys, sortKey{expr: filters.sortColumn(), desc: filters.sortDirection() == "DESC"})
+	}
+	keys = append(keys, sortKey{expr: "id", desc: filters.Sort == "-id"})
+
+	where, orderBy, cursorArgs, err := filters.keyset(keys, 12)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var keyColumns []string
+	for _, key := range keys {
+		keyColumns = append(keyColumns, "("+key.expr+")::text")
+	}
+
+	query := fmt.Sprintf(`SELECT count(*) OVER(), id, title, categories, skills, min_age_months, COALESCE(max_age_months, 0),
+	COALESCE(manufacturer_id, 0), COALESCE(manufacturer, ''), value,
+	rating_average, rating_count, rating_histogram, loan_count,
+`+toyUnitsAvailable+`, `+toyUnitsTotal+`,
+	%s AS relevance,
+	CASE WHEN $1 = '' THEN '' ELSE %s END,
+	%s
+FROM toys
+WHERE %s
+AND %s
+ORDER BY %s
+LIMIT $9 OFFSET $10`, search.rank(), snippet, strings.Join(keyColumns, ", "), search.conditions(), where, orderBy)
 
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(search.args(), filters.limit(), filters.offset(), pq.Array(search.BoostSkills))
+	args = append(args, cursorArgs...)
 	rows, err := t.DB.QueryContext(ctx, query, args...)
 
 	if err != nil {
@@ -207,31 +665,67 @@ LIMIT $6 OFFSET $7`, filters.sortColumn(), filters.sortDirection())
 
 	totalRecords := 0
 	toys := []*Toy{}
+	toyKeys := [][]string{}
 
 	for rows.Next() {
 		var toy Toy
+		var manufacturerName string
 
-		err := rows.Scan(
+		keyValues := make([]string, len(keys))
+		dest := []any{
 			&totalRecords,
 			&toy.ID,
 			&toy.Title,
 			pq.Array(&toy.Categories),
 			pq.Array(&toy.Skills),
-			&toy.RecommendedAge,
+			&toy.RecommendedAge.Min,
+			&toy.RecommendedAge.Max,
+			&toy.ManufacturerID,
+			&manufacturerName,
 			&toy.Value,
-		)
+			&toy.Rating.Average,
+			&toy.Rating.Count,
+			pq.Array(&toy.Rating.Histogram),
+			&toy.Popularity,
+			&toy.UnitsAvailable,
+			&toy.UnitsTotal,
+			&toy.Relevance,
+			&toy.Snippet,
+		}
+		for i := range keyValues {
+			dest = append(dest, &keyValues[i])
+		}
+
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 
+		toy.IsAvailable = toy.UnitsAvailable > 0
+		toy.setManufacturer(manufacturerName)
+
 		toys = append(toys, &toy)
+		toyKeys = append(toyKeys, keyValues)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, Metadata{}, err
 	}
 
-	metadata := filters.calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata := calculateKeysetMetadata(filters, totalRecords, toys, toyKeys)
 
 	return toys, metadata, err
 }
+
+func toyError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "toys_manufacturer_id_fkey":
+			return ErrUnknownManufacturer
+		case "toys_sku_key":
+			return ErrDuplicateSKU
+		}
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTermsCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups [][]string
+		want   string
+	}{
+		{
+			name: "no terms",
+			want: "($2::text[] IS NOT NULL)",
+		},
+		{
+			name:   "one term",
+			groups: [][]string{{"vehicles", "cars", "trains"}},
+			want:   "($2::text[] IS NOT NULL AND categories && ($2::text[])[1:3])",
+		},
+		{
+			name:   "several terms",
+			groups: [][]string{{"vehicles", "cars", "trains"}, {"wooden"}, {"music", "drums"}},
+			want:   "($2::text[] IS NOT NULL AND categories && ($2::text[])[1:3] AND categories && ($2::text[])[4:4] AND categories && ($2::text[])[5:6])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := termsCondition("categories", "$2", tt.groups); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	got := flatten([][]string{{"vehicles", "cars"}, {"wooden"}})
+
+	if want := []string{"vehicles", "cars", "wooden"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	if got := flatten(nil); got == nil || len(got) != 0 {
+		t.Errorf("got %v; want an empty slice", got)
+	}
+}
@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"oynas/internal/validator"
+	"time"
+)
+
+const (
+	UnitInStock  = "in_stock"
+	UnitRented   = "rented"
+	UnitCleaning = "cleaning"
+	UnitRetired  = "retired"
+)
+
+var UnitConditions = []string{"new", "like_new", "good", "fair", "poor"}
+
+var (
+	ErrDuplicateSerial  = errors.New("duplicate serial")
+	ErrDuplicateBarcode = errors.New("duplicate barcode")
+	ErrUnitRented       = errors.New("unit is rented")
+)
+
+type ToyUnit struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ToyID     int64     `json:"toy_id"`
+	Serial    string    `json:"serial"`
+	Barcode   string    `json:"barcode,omitempty"`
+	Condition string    `json:"condition"`
+	Location  string    `json:"location"`
+	Status    string    `json:"status"`
+	Version   int       `json:"version"`
+}
+
+func ValidateToyUnit(v *validator.Validator, unit *ToyUnit) {
+	v.Check(unit.Serial != "", "serial", "must be provided")
+	v.Check(len(unit.Serial) <= 100, "serial", "must not be more than 100 bytes long")
+	v.Check(len(unit.Barcode) <= 100, "barcode", "must not be more than 100 bytes long")
+	v.Check(validator.PermittedValue(unit.Condition, UnitConditions...), "condition", "must be new, like_new, good, fair or poor")
+	v.Check(len(unit.Location) <= 200, "location", "must not be more than 200 bytes long")
+	v.Check(validator.PermittedValue(unit.Status, UnitInStock, UnitRented, UnitCleaning, UnitRetired), "status", "must be in_stock, rented, cleaning or retired")
+}
+
+type ToyUnitModel struct {
+	DB *sql.DB
+}
+
+func (u ToyUnitModel) Insert(unit *ToyUnit) error {
+	query := `
+INSERT INTO toy_units (toy_id, serial, barcode, condition, location, status)
+VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+RETURNING id, created_at, version`
+
+	args := []any{unit.ToyID, unit.Serial, unit.Barcode, unit.Condition, unit.Location, unit.Status}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&unit.ID, &unit.CreatedAt, &unit.Version)
+	if err != nil {
+		return unitError(err)
+	}
+	return nil
+}
+
+func (u ToyUnitModel) Get(id int64) (*ToyUnit, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+SELECT id, created_at, toy_id, serial, COALESCE(barcode, ''), condition, location, status, version
+FROM toy_units
+WHERE id = $1`
+
+	var unit ToyUnit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, id).Scan(
+		&unit.ID,
+		&unit.CreatedAt,
+		&unit.ToyID,
+		&unit.Serial,
+		&unit.Barcode,
+		&unit.Condition,
+		&unit.Location,
+		&unit.Status,
+		&unit.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &unit, nil
+}
+
+// Update saves a unit's details. Units move in and out of the rented state
+// only through loans, so a rented unit can't be updated here and no unit can
+// be marked as rented.
+func (u ToyUnitModel) Update(unit *ToyUnit) error {
+	if unit.Status == UnitRented {
+		return ErrUnitRented
+	}
+
+	query := `
+UPDATE toy_units
+SET serial = $1, barcode = NULLIF($2, ''), condition = $3, location = $4, status = $5, version = version + 1
+WHERE id = $6 AND version = $7 AND status <> 'rented'
+RETURNING version`
+
+	args := []any{unit.Serial, unit.Barcode, unit.Condition, unit.Location, unit.Status, unit.ID, unit.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&unit.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return unitError(err)
+		}
+	}
+	return nil
+}
+
+func (u ToyUnitModel) GetAllForToy(toyID int64, status string) ([]*ToyUnit, error) {
+	query := `
+SELECT id, created_at, toy_id, serial, COALESCE(barcode, ''), condition, location, status, version
+FROM toy_units
+WHERE toy_id = $1
+AND (status = $2 OR $2 = '')
+ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := u.DB.QueryContext(ctx, query, toyID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []*ToyUnit{}
+
+	for rows.Next() {
+		var unit ToyUnit
+
+		err := rows.Scan(
+			&unit.ID,
+			&unit.CreatedAt,
+			&unit.ToyID,
+			&unit.Serial,
+			&unit.Barcode,
+			&unit.Condition,
+			&unit.Location,
+			&unit.Status,
+			&unit.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		units = append(units, &unit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+func unitError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "toy_units_serial_key":
+			return ErrDuplicateSerial
+		case "toy_units_barcode_key":
+			return ErrDuplicateBarcode
+		case "toy_units_toy_id_fkey":
+			return ErrRecordNotFound
+		}
+	}
+	return err
+}
+
+// reserveUnit picks an in-stock unit of the toy and marks it as rented. The
+// caller must hold the toy's row lock. A unit held for somebody at the front
+// of the wait list is only handed to that user.
+func reserveUnit(ctx context.Context, tx *sql.Tx, toyID, userID int64) (int64, error) {
+	var inStock int
+	err := tx.QueryRowContext(ctx, `SELECT count(*) FROM toy_units WHERE toy_id = $1 AND status = 'in_stock'`, toyID).Scan(&inStock)
+	if err != nil {
+		return 0, err
+	}
+	if inStock == 0 {
+		return 0, ErrToyUnavailable
+	}
+
+	err = claimHold(ctx, tx, toyID, userID, inStock)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+UPDATE toy_units SET status = 'rented', version = version + 1
+WHERE id = (
+	SELECT id FROM toy_units
+	WHERE toy_id = $1 AND status = 'in_stock'
+	ORDER BY id
+	LIMIT 1
+	FOR UPDATE
+)
+RETURNING id`
+
+	var unitID int64
+	err = tx.QueryRowContext(ctx, query, toyID).Scan(&unitID)
+	if err != nil {
+		return 0, err
+	}
+
+	return unitID, nil
+}
@@ -11,18 +11,18 @@ import (
 )
 
 type User struct {
-	ID         int64     `json:"id"`
-	CreatedAt  time.Time `json:"created_at"`
-	Name       string    `json:"name"`
-	Role       string    `json:"role"`
-	Email      string    `json:"email"`
-	Password   password  `json:"-"`
-	Activated  bool      `json:"activated"`
-	PayingTime string    `json:"-"`
-	Plan       string    `json:"-"`
-	Balance    int       `json:"-"`
-	Bucket     []string  `json:"bucket"`
-	Version    int       `json:"-"`
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Email     string     `json:"email"`
+	Password  password   `json:"-"`
+	Activated bool       `json:"activated"`
+	PlanID    int64      `json:"plan_id,omitempty"`
+	PaidUntil *time.Time `json:"paid_until,omitempty"`
+	Balance   int64      `json:"-"`
+	Bucket    []int64    `json:"bucket,omitempty"`
+	Version   int        `json:"-"`
 }
 
 type password struct {
@@ -116,7 +116,7 @@ RETURNING id, created_at, version`
 }
 
 func (u UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT id, name, role, email, created_at, password_hash, activated, version FROM users
+	query := `SELECT id, name, role, email, created_at, password_hash, activated, COALESCE(plan_id, 0), paid_until, version FROM users
 WHERE email = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -132,6 +132,8 @@ WHERE email = $1`
 		&user.CreatedAt,
 		&user.Password.hash,
 		&user.Activated,
+		&user.PlanID,
+		&user.PaidUntil,
 		&user.Version,
 	)
 	if err != nil {
@@ -184,7 +186,7 @@ func (u UserModel) Update(user *User) error {
 func (u UserModel) GetForToken(scope string, tokenPlainText string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
-	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	query := `SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, COALESCE(users.plan_id, 0), users.paid_until, users.version
 FROM users
 INNER JOIN tokens
 ON users.id = tokens.id
@@ -206,6 +208,8 @@ AND tokens.expiry > $3`
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.PlanID,
+		&user.PaidUntil,
 		&user.Version,
 	)
 	if err != nil {
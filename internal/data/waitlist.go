@@ -0,0 +1,283 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	WaitListWaiting   = "waiting"
+	WaitListOffered   = "offered"
+	WaitListFulfilled = "fulfilled"
+	WaitListExpired   = "expired"
+)
+
+var (
+	ErrAlreadyOnWaitList = errors.New("already on wait list")
+	ErrToyOnHold         = errors.New("toy is held for another user")
+)
+
+type WaitListEntry struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ToyID      int64      `json:"toy_id"`
+	ToyTitle   string     `json:"toy_title,omitempty"`
+	UserID     int64      `json:"user_id"`
+	UserName   string     `json:"-"`
+	UserEmail  string     `json:"-"`
+	Status     string     `json:"status"`
+	Position   int        `json:"position,omitempty"`
+	OfferedAt  *time.Time `json:"offered_at,omitempty"`
+	HoldExpiry *time.Time `json:"hold_expiry,omitempty"`
+	Version    int        `json:"-"`
+}
+
+type WaitListModel struct {
+	DB *sql.DB
+}
+
+// Join appends the user to the toy's queue. Queue order is the order of the
+// bigserial ids, and the partial unique index on (toy_id, user_id) keeps a
+// user from holding two places in the same queue, even under concurrent joins.
+func (m WaitListModel) Join(toyID, userID int64) (*WaitListEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var toyExists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM toys WHERE id = $1 AND archived_at IS NULL)`, toyID).Scan(&toyExists)
+	if err != nil {
+		return nil, err
+	}
+	if !toyExists {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+INSERT INTO wait_list (toy_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (toy_id, user_id) WHERE status IN ('waiting', 'offered') DO NOTHING
+RETURNING id`
+
+	var id int64
+	err = m.DB.QueryRowContext(ctx, query, toyID, userID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrAlreadyOnWaitList
+		default:
+			return nil, err
+		}
+	}
+
+	return m.GetForUser(toyID, userID)
+}
+
+func (m WaitListModel) Leave(toyID, userID int64) error {
+	query := `
+DELETE FROM wait_list
+WHERE toy_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, toyID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m WaitListModel) GetForUser(toyID, userID int64) (*WaitListEntry, error) {
+	query := `
+SELECT w.id, w.created_at, w.toy_id, toys.title, w.user_id, w.status, w.offered_at, w.hold_expiry, w.version,
+	(SELECT count(*) FROM wait_list o WHERE o.toy_id = w.toy_id AND o.status IN ('waiting', 'offered') AND o.id <= w.id)
+FROM wait_list w
+INNER JOIN toys ON toys.id = w.toy_id
+WHERE w.toy_id = $1 AND w.user_id = $2 AND w.status IN ('waiting', 'offered')`
+
+	var entry WaitListEntry
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, toyID, userID).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.ToyID,
+		&entry.ToyTitle,
+		&entry.UserID,
+		&entry.Status,
+		&entry.OfferedAt,
+		&entry.HoldExpiry,
+		&entry.Version,
+		&entry.Position,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// OfferNext offers a free unit of a toy to the first user in its queue and
+// holds it for them until holdTTL passes. Stale offers are expired on the way.
+// It returns ErrRecordNotFound when every in-stock unit is already held or
+// nobody is waiting for the toy.
+func (m WaitListModel) OfferNext(toyID int64, holdTTL time.Duration) (*WaitListEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var toyTitle string
+	err = tx.QueryRowContext(ctx, `SELECT title FROM toys WHERE id = $1 FOR UPDATE`, toyID).Scan(&toyTitle)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE wait_list SET status = 'expired', version = version + 1
+WHERE toy_id = $1 AND status = 'offered' AND hold_expiry <= now()`, toyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var busy bool
+	err = tx.QueryRowContext(ctx, `
+SELECT (SELECT count(*) FROM toy_units WHERE toy_id = $1 AND status = 'in_stock')
+	<= (SELECT count(*) FROM wait_list WHERE toy_id = $1 AND status = 'offered')`, toyID).Scan(&busy)
+	if err != nil {
+		return nil, err
+	}
+	if busy {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+UPDATE wait_list
+SET status = 'offered', offered_at = now(), hold_expiry = $2, version = version + 1
+WHERE id = (
+	SELECT id FROM wait_list
+	WHERE toy_id = $1 AND status = 'waiting'
+	ORDER BY id
+	LIMIT 1
+)
+RETURNING id, created_at, toy_id, user_id, status, offered_at, hold_expiry, version`
+
+	entry := WaitListEntry{ToyTitle: toyTitle, Position: 1}
+
+	err = tx.QueryRowContext(ctx, query, toyID, time.Now().Add(holdTTL)).Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.ToyID,
+		&entry.UserID,
+		&entry.Status,
+		&entry.OfferedAt,
+		&entry.HoldExpiry,
+		&entry.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT name, email FROM users WHERE id = $1`, entry.UserID).Scan(&entry.UserName, &entry.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// GetLapsedHolds returns the ids of the toys with an offer whose hold has
+// expired, so that they can be offered to the next user in line.
+func (m WaitListModel) GetLapsedHolds() ([]int64, error) {
+	query := `
+SELECT DISTINCT toy_id
+FROM wait_list
+WHERE status = 'offered' AND hold_expiry <= now()
+ORDER BY toy_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	toyIDs := []int64{}
+
+	for rows.Next() {
+		var toyID int64
+
+		err := rows.Scan(&toyID)
+		if err != nil {
+			return nil, err
+		}
+
+		toyIDs = append(toyIDs, toyID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return toyIDs, nil
+}
+
+// claimHold is called while checking a toy out, with inStock being the number
+// of the toy's units on the shelf. It fails when every one of them is held for
+// somebody else and marks the borrower's own queue entry as fulfilled.
+func claimHold(ctx context.Context, tx *sql.Tx, toyID, userID int64, inStock int) error {
+	var heldForOthers int
+	err := tx.QueryRowContext(ctx, `
+SELECT count(*) FROM wait_list
+WHERE toy_id = $1 AND user_id <> $2 AND status = 'offered' AND hold_expiry > now()`, toyID, userID).Scan(&heldForOthers)
+	if err != nil {
+		return err
+	}
+	if heldForOthers >= inStock {
+		return ErrToyOnHold
+	}
+
+	_, err = tx.ExecContext(ctx, `
+UPDATE wait_list SET status = 'fulfilled', version = version + 1
+WHERE toy_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')`, toyID, userID)
+	return err
+}
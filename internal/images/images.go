@@ -0,0 +1,189 @@
+// Package images checks uploaded images and makes thumbnails of them using
+// only the standard library decoders and encoders.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels bounds the size of a decoded image, so that a small file can't
+// expand into gigabytes of memory.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image has too many pixels")
+)
+
+// extensions are the file extensions of the accepted content types.
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Size is a thumbnail size. Thumbnails are Width pixels wide, or as wide as
+// the original if that is narrower, and keep its aspect ratio.
+type Size struct {
+	Name  string
+	Width int
+}
+
+var Sizes = []Size{
+	{Name: "small", Width: 160},
+	{Name: "medium", Width: 480},
+	{Name: "large", Width: 1024},
+}
+
+// Variant is one encoded version of an image.
+type Variant struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	// Original is the uploaded file, unchanged.
+	Original Variant
+	// Thumbnails follow the order of Sizes.
+	Thumbnails []Variant
+}
+
+// Sniff returns the content type of an image from its first bytes, ignoring
+// whatever type the client claimed it has.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return "", ErrUnsupportedFormat
+	}
+	return contentType, nil
+}
+
+// Process checks an uploaded image and makes its thumbnails. Thumbnails of
+// PNG and GIF images are PNGs, to keep their transparency, and JPEGs
+// otherwise. Only the first frame of an animated GIF is kept.
+func Process(data []byte) (*Image, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	img := &Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Original: Variant{
+			Name:        "original",
+			ContentType: contentType,
+			Extension:   extensions[contentType],
+			Data:        data,
+		},
+	}
+
+	rgba := toRGBA(src)
+
+	for _, size := range Sizes {
+		thumbnail := Resize(rgba, size.Width)
+
+		variant := Variant{Name: size.Name}
+
+		var buf bytes.Buffer
+		if contentType == "image/jpeg" {
+			variant.ContentType, variant.Extension = "image/jpeg", "jpg"
+			err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+		} else {
+			variant.ContentType, variant.Extension = "image/png", "png"
+			err = png.Encode(&buf, thumbnail)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		variant.Data = buf.Bytes()
+		img.Thumbnails = append(img.Thumbnails, variant)
+	}
+
+	return img, nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// Resize scales an image down to width pixels, averaging the source pixels
+// each thumbnail pixel covers. Images already narrower are returned as is.
+func Resize(src *image.RGBA, width int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= width {
+		return src
+	}
+
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,108 @@
+// Package jobs runs recurring background work next to the API server. Each
+// job runs in its own goroutine, never overlaps with itself, survives panics
+// and is given a context that is cancelled when the runner stops.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"oynas/internal/jsonlog"
+	"sync"
+	"time"
+)
+
+// Func is the work done by one run of a job. It should return early once ctx
+// is cancelled.
+type Func func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	fn       Func
+}
+
+type Runner struct {
+	logger  *jsonlog.Logger
+	jobs    []job
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	started bool
+}
+
+func New(logger *jsonlog.Logger) *Runner {
+	return &Runner{logger: logger}
+}
+
+// Every registers a job that runs once when the runner starts and then every
+// interval. Jobs must be registered before Start is called.
+func (r *Runner) Every(name string, interval time.Duration, fn Func) {
+	r.jobs = append(r.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+func (r *Runner) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return
+	}
+	r.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, j)
+	}
+}
+
+// Stop cancels the jobs' context and waits for running jobs to return.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, j job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		r.run(ctx, j)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, j job) {
+	start := time.Now()
+
+	defer func() {
+		if err := recover(); err != nil {
+			r.logger.PrintError(fmt.Errorf("%s", err), map[string]string{"job": j.name})
+		}
+	}()
+
+	err := j.fn(ctx)
+	if err != nil {
+		r.logger.PrintError(err, map[string]string{"job": j.name})
+		return
+	}
+
+	r.logger.PrintInfo("job finished", map[string]string{
+		"job":      j.name,
+		"duration": time.Since(start).String(),
+	})
+}
@@ -0,0 +1,211 @@
+// Package recommend ranks catalog toys for a user from the toys they have
+// rented before. It only works on the plain values passed to it and never
+// touches the database, so rankings can be reproduced and tuned offline from
+// fixture data.
+package recommend
+
+import (
+	"math"
+	"sort"
+)
+
+// Item is a toy as seen by the recommender. MinAge and MaxAge are in months;
+// a zero MaxAge means the toy has no upper age limit.
+type Item struct {
+	ID         int64
+	Skills     []string
+	Categories []string
+	MinAge     int
+	MaxAge     int
+}
+
+// ageDistance reports how far, in months, age is outside the item's age range.
+func (i Item) ageDistance(age int) int {
+	switch {
+	case age < i.MinAge:
+		return i.MinAge - age
+	case i.MaxAge > 0 && age > i.MaxAge:
+		return age - i.MaxAge
+	default:
+		return 0
+	}
+}
+
+// Profile is what is known about the user. FavoriteCategories are the ones the
+// user picked, kept apart from the History. ChildAge is in months and is zero
+// when unknown; Interests are the child's interests, matched against skills.
+type Profile struct {
+	History            []Item
+	FavoriteCategories []string
+	ChildAge           int
+	Interests          []string
+}
+
+// Weights sets how much each signal contributes to an item's score.
+type Weights struct {
+	Skills     float64
+	Categories float64
+	Favorites  float64
+	Interests  float64
+	Age        float64
+	// AgeTolerance is the number of months outside an item's age range at
+	// which the age penalty reaches its full weight.
+	AgeTolerance int
+}
+
+var DefaultWeights = Weights{
+	Skills:       1,
+	Categories:   1,
+	Favorites:    1.5,
+	Interests:    1.5,
+	Age:          2,
+	AgeTolerance: 12,
+}
+
+const (
+	ReasonSkills    = "similar_skills"
+	ReasonCategory  = "similar_categories"
+	ReasonFavorite  = "favorite_category"
+	ReasonInterests = "matches_interests"
+	ReasonAge       = "fits_child_age"
+)
+
+type Recommendation struct {
+	ID      int64
+	Score   float64
+	Reasons []string
+}
+
+// Rank scores every candidate the user hasn't rented yet and returns the best
+// limit of them, highest score first. Ties are broken by ID so that the same
+// input always gives the same ranking.
+func Rank(profile Profile, candidates []Item, weights Weights, limit int) []Recommendation {
+	rented := make(map[int64]bool, len(profile.History))
+	for _, item := range profile.History {
+		rented[item.ID] = true
+	}
+
+	skills := frequencies(profile.History, func(i Item) []string { return i.Skills })
+	categories := frequencies(profile.History, func(i Item) []string { return i.Categories })
+
+	favorites := make(map[string]bool, len(profile.FavoriteCategories))
+	for _, category := range profile.FavoriteCategories {
+		favorites[category] = true
+	}
+
+	interests := make(map[string]bool, len(profile.Interests))
+	for _, interest := range profile.Interests {
+		interests[interest] = true
+	}
+
+	recommendations := []Recommendation{}
+
+	for _, item := range candidates {
+		if rented[item.ID] {
+			continue
+		}
+
+		recommendation := Recommendation{ID: item.ID, Reasons: []string{}}
+
+		if score := overlap(item.Skills, skills); score > 0 {
+			recommendation.Score += weights.Skills * score
+			recommendation.Reasons = append(recommendation.Reasons, ReasonSkills)
+		}
+
+		if score := overlap(item.Categories, categories); score > 0 {
+			recommendation.Score += weights.Categories * score
+			recommendation.Reasons = append(recommendation.Reasons, ReasonCategory)
+		}
+
+		if score := share(item.Categories, favorites); score > 0 {
+			recommendation.Score += weights.Favorites * score
+			recommendation.Reasons = append(recommendation.Reasons, ReasonFavorite)
+		}
+
+		if score := share(item.Skills, interests); score > 0 {
+			recommendation.Score += weights.Interests * score
+			recommendation.Reasons = append(recommendation.Reasons, ReasonInterests)
+		}
+
+		if profile.ChildAge > 0 {
+			distance := item.ageDistance(profile.ChildAge)
+			if distance == 0 {
+				recommendation.Score += weights.Age
+				recommendation.Reasons = append(recommendation.Reasons, ReasonAge)
+			} else {
+				penalty := 1.0
+				if weights.AgeTolerance > 0 {
+					penalty = math.Min(float64(distance)/float64(weights.AgeTolerance), 1)
+				}
+				recommendation.Score -= weights.Age * penalty
+			}
+		}
+
+		recommendations = append(recommendations, recommendation)
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].ID < recommendations[j].ID
+	})
+
+	if limit >= 0 && len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations
+}
+
+// frequencies returns, for every value found in the history, the share of
+// history items that have it.
+func frequencies(history []Item, values func(Item) []string) map[string]float64 {
+	counts := map[string]float64{}
+	if len(history) == 0 {
+		return counts
+	}
+
+	for _, item := range history {
+		for _, value := range values(item) {
+			counts[value]++
+		}
+	}
+
+	for value := range counts {
+		counts[value] /= float64(len(history))
+	}
+
+	return counts
+}
+
+// overlap is the average frequency of the values in the history, between 0
+// and 1.
+func overlap(values []string, frequency map[string]float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, value := range values {
+		total += frequency[value]
+	}
+
+	return total / float64(len(values))
+}
+
+// share is the fraction of values found in set.
+func share(values []string, set map[string]bool) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var found int
+	for _, value := range values {
+		if set[value] {
+			found++
+		}
+	}
+
+	return float64(found) / float64(len(values))
+}
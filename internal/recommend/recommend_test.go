@@ -0,0 +1,128 @@
+package recommend
+
+import (
+	"reflect"
+	"testing"
+)
+
+var history = []Item{
+	{ID: 1, Skills: []string{"motor", "logic"}, Categories: []string{"blocks"}},
+	{ID: 2, Skills: []string{"logic"}, Categories: []string{"puzzles"}},
+	{ID: 3, Skills: []string{"logic", "music"}, Categories: []string{"blocks"}},
+}
+
+var candidates = []Item{
+	{ID: 1, Skills: []string{"motor", "logic"}, Categories: []string{"blocks"}},
+	{ID: 10, Skills: []string{"logic"}, Categories: []string{"blocks"}, MinAge: 24, MaxAge: 48},
+	{ID: 11, Skills: []string{"art"}, Categories: []string{"crafts"}},
+	{ID: 12, Skills: []string{"music"}, Categories: []string{"puzzles"}, MinAge: 60},
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		items   []Item
+		limit   int
+		ids     []int64
+		reasons [][]string
+	}{
+		{
+			name:    "history",
+			profile: Profile{History: history},
+			items:   candidates,
+			limit:   10,
+			ids:     []int64{10, 12, 11},
+			reasons: [][]string{
+				{ReasonSkills, ReasonCategory},
+				{ReasonSkills, ReasonCategory},
+				{},
+			},
+		},
+		{
+			name:    "favorites and interests",
+			profile: Profile{History: history, FavoriteCategories: []string{"crafts"}, Interests: []string{"art"}},
+			items:   candidates,
+			limit:   10,
+			ids:     []int64{11, 10, 12},
+			reasons: [][]string{
+				{ReasonFavorite, ReasonInterests},
+				{ReasonSkills, ReasonCategory},
+				{ReasonSkills, ReasonCategory},
+			},
+		},
+		{
+			name:    "child age",
+			profile: Profile{History: history, ChildAge: 30},
+			items:   candidates,
+			limit:   10,
+			ids:     []int64{10, 11, 12},
+			reasons: [][]string{
+				{ReasonSkills, ReasonCategory, ReasonAge},
+				{ReasonAge},
+				{ReasonSkills, ReasonCategory},
+			},
+		},
+		{
+			name:    "limit",
+			profile: Profile{History: history},
+			items:   candidates,
+			limit:   1,
+			ids:     []int64{10},
+			reasons: [][]string{{ReasonSkills, ReasonCategory}},
+		},
+		{
+			name:    "ties broken by id",
+			profile: Profile{},
+			items:   []Item{candidates[3], candidates[2], candidates[1]},
+			limit:   10,
+			ids:     []int64{10, 11, 12},
+			reasons: [][]string{{}, {}, {}},
+		},
+		{
+			name:    "zero limit",
+			profile: Profile{History: history},
+			items:   candidates,
+			limit:   0,
+			ids:     []int64{},
+			reasons: [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendations := Rank(tt.profile, tt.items, DefaultWeights, tt.limit)
+
+			ids := []int64{}
+			reasons := [][]string{}
+			for _, recommendation := range recommendations {
+				ids = append(ids, recommendation.ID)
+				reasons = append(reasons, recommendation.Reasons)
+			}
+
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got ids %v; want %v", ids, tt.ids)
+			}
+			if !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("got reasons %v; want %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestRankAgePenalty(t *testing.T) {
+	profile := Profile{ChildAge: 30}
+	items := []Item{
+		{ID: 1, MinAge: 60},
+		{ID: 2, MinAge: 36},
+	}
+
+	recommendations := Rank(profile, items, DefaultWeights, 10)
+
+	want := map[int64]float64{1: -2, 2: -1}
+	for _, recommendation := range recommendations {
+		if recommendation.Score != want[recommendation.ID] {
+			t.Errorf("got score %v for %d; want %v", recommendation.Score, recommendation.ID, want[recommendation.ID])
+		}
+	}
+}
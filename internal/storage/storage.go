@@ -0,0 +1,62 @@
+// Package storage keeps uploaded files, such as toy images, behind a Store so
+// that the local filesystem backend can be swapped for an object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Object is a stored file opened for reading. Its Content is closed by the
+// caller.
+type Object struct {
+	Content     io.ReadSeekCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// Store is a flat namespace of files addressed by slash-separated keys such
+// as "toys/3f2a/small.jpg".
+type Store interface {
+	// Put writes a file, replacing any file with the same key.
+	Put(ctx context.Context, key string, content io.Reader, contentType string) error
+	// Get opens a file, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes a file. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is the stable address clients fetch a file from.
+	URL(key string) string
+}
+
+// ValidKey reports whether a key is made of non-empty path segments of
+// letters, digits, dots, dashes and underscores, none of them "." or "..".
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 500 {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -56,7 +56,7 @@ func Unique[T comparable](values []T) bool {
 
 func (v *Validator) ImageUrlsCheck(images []string) bool {
 	for i := range images {
-		if !strings.HasPrefix(images[i], "https://") {
+		if !strings.HasPrefix(images[i], "https://") && !strings.HasPrefix(images[i], "/v1/images/") {
 			return false
 		}
 	}
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('loans:read', 'loans:write');
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS is_available text;
+DROP TABLE IF EXISTS loans;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS loans (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    toy_id bigint NOT NULL REFERENCES toys ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    due_date timestamp(0) with time zone NOT NULL,
+    returned_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS loans_open_toy_idx ON loans (toy_id) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS loans_user_idx ON loans (user_id);
+
+ALTER TABLE toys DROP COLUMN IF EXISTS is_available;
+
+INSERT INTO permissions (code)
+VALUES
+('loans:read'),
+('loans:write');
@@ -0,0 +1,2 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS wait_list text[];
+DROP TABLE IF EXISTS wait_list;
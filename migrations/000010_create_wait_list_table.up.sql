@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS wait_list (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    toy_id bigint NOT NULL REFERENCES toys ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'waiting',
+    offered_at timestamp(0) with time zone,
+    hold_expiry timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE wait_list ADD CONSTRAINT wait_list_status_check CHECK (status IN ('waiting', 'offered', 'fulfilled', 'expired'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS wait_list_active_idx ON wait_list (toy_id, user_id) WHERE status IN ('waiting', 'offered');
+
+INSERT INTO wait_list (toy_id, user_id)
+SELECT toys.id, users.id
+FROM toys
+CROSS JOIN LATERAL unnest(toys.wait_list) WITH ORDINALITY AS entries(value, position)
+INNER JOIN users ON users.id::text = entries.value OR users.email = entries.value::citext
+GROUP BY toys.id, users.id
+ORDER BY toys.id, min(entries.position);
+
+ALTER TABLE toys DROP COLUMN IF EXISTS wait_list;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS checkout_requests;
+ALTER TABLE users ALTER COLUMN bucket DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN bucket DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN bucket TYPE text[] USING bucket::text[];
@@ -0,0 +1,25 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bucket_toys bigint[] NOT NULL DEFAULT '{}';
+
+UPDATE users SET bucket_toys = ARRAY(
+    SELECT toys.id
+    FROM unnest(users.bucket) WITH ORDINALITY AS items(value, position)
+    INNER JOIN toys ON toys.id::text = items.value
+    ORDER BY items.position
+);
+
+ALTER TABLE users DROP COLUMN IF EXISTS bucket;
+ALTER TABLE users RENAME COLUMN bucket_toys TO bucket;
+
+CREATE TABLE IF NOT EXISTS checkout_requests (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    toy_ids bigint[] NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE checkout_requests ADD CONSTRAINT checkout_requests_status_check CHECK (status IN ('pending', 'approved', 'rejected'));
+ALTER TABLE checkout_requests ADD CONSTRAINT checkout_requests_toy_ids_length_check CHECK (array_length(toy_ids, 1) >= 1);
+
+CREATE INDEX IF NOT EXISTS checkout_requests_user_idx ON checkout_requests (user_id);
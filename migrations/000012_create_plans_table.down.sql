@@ -0,0 +1,8 @@
+ALTER TABLE users RENAME COLUMN paid_until TO paying_time;
+ALTER TABLE users ALTER COLUMN paying_time TYPE text USING paying_time::text;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan text;
+UPDATE users SET plan = plans.name FROM plans WHERE plans.id = users.plan_id;
+ALTER TABLE users DROP COLUMN IF EXISTS plan_id;
+
+DROP TABLE IF EXISTS plans;
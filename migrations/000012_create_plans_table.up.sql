@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS plans (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    name text UNIQUE NOT NULL,
+    price integer NOT NULL,
+    max_toys integer NOT NULL,
+    max_value integer NOT NULL,
+    billing_period_days integer NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE plans ADD CONSTRAINT plans_price_check CHECK (price >= 0);
+ALTER TABLE plans ADD CONSTRAINT plans_max_toys_check CHECK (max_toys >= 1);
+ALTER TABLE plans ADD CONSTRAINT plans_max_value_check CHECK (max_value >= 1);
+ALTER TABLE plans ADD CONSTRAINT plans_billing_period_days_check CHECK (billing_period_days >= 1);
+
+INSERT INTO plans (name, price, max_toys, max_value, billing_period_days)
+VALUES
+('basic', 9900, 2, 30000, 30),
+('standard', 14900, 4, 80000, 30),
+('premium', 24900, 6, 200000, 30);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan_id bigint REFERENCES plans ON DELETE SET NULL;
+UPDATE users SET plan_id = plans.id FROM plans WHERE plans.name = lower(trim(users.plan));
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
+
+ALTER TABLE users ALTER COLUMN paying_time TYPE timestamp(0) with time zone
+    USING CASE WHEN paying_time ~ '^\d{4}-\d{2}-\d{2}' THEN paying_time::timestamp with time zone END;
+ALTER TABLE users RENAME COLUMN paying_time TO paid_until;
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'ledger:write';
+DROP TABLE IF EXISTS ledger_entries;
+DROP FUNCTION IF EXISTS ledger_entries_append_only();
+ALTER TABLE users ALTER COLUMN balance TYPE integer;
@@ -0,0 +1,40 @@
+ALTER TABLE users ALTER COLUMN balance TYPE bigint;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE RESTRICT,
+    kind text NOT NULL,
+    amount bigint NOT NULL,
+    balance_after bigint NOT NULL,
+    description text NOT NULL DEFAULT '',
+    idempotency_key text NOT NULL,
+    UNIQUE (user_id, idempotency_key)
+);
+
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_amount_check CHECK (
+    (kind = 'top_up' AND amount > 0) OR
+    (kind = 'refund' AND amount > 0) OR
+    (kind = 'charge' AND amount < 0) OR
+    (kind = 'adjustment' AND amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_user_idx ON ledger_entries (user_id, created_at);
+
+CREATE OR REPLACE FUNCTION ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_append_only();
+
+INSERT INTO ledger_entries (user_id, kind, amount, balance_after, description, idempotency_key)
+SELECT id, 'adjustment', balance, balance, 'opening balance', 'opening-balance'
+FROM users
+WHERE balance <> 0;
+
+INSERT INTO permissions (code)
+VALUES
+('ledger:write');
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code IN ('units:read', 'units:write');
+DROP INDEX IF EXISTS loans_open_unit_idx;
+ALTER TABLE loans DROP COLUMN IF EXISTS unit_id;
+CREATE UNIQUE INDEX IF NOT EXISTS loans_open_toy_idx ON loans (toy_id) WHERE returned_at IS NULL;
+DROP TABLE IF EXISTS toy_units;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS toy_units (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    toy_id bigint NOT NULL REFERENCES toys ON DELETE CASCADE,
+    serial text UNIQUE NOT NULL,
+    barcode text UNIQUE,
+    condition text NOT NULL DEFAULT 'good',
+    location text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'in_stock',
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE toy_units ADD CONSTRAINT toy_units_condition_check CHECK (condition IN ('new', 'like_new', 'good', 'fair', 'poor'));
+ALTER TABLE toy_units ADD CONSTRAINT toy_units_status_check CHECK (status IN ('in_stock', 'rented', 'cleaning', 'retired'));
+
+CREATE INDEX IF NOT EXISTS toy_units_toy_status_idx ON toy_units (toy_id, status);
+
+-- Every existing catalog entry gets one unit so that availability keeps working.
+INSERT INTO toy_units (toy_id, serial, status)
+SELECT toys.id, 'LEGACY-' || toys.id,
+    CASE WHEN EXISTS (SELECT 1 FROM loans WHERE loans.toy_id = toys.id AND loans.returned_at IS NULL) THEN 'rented' ELSE 'in_stock' END
+FROM toys;
+
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS unit_id bigint REFERENCES toy_units ON DELETE CASCADE;
+UPDATE loans SET unit_id = toy_units.id FROM toy_units WHERE toy_units.toy_id = loans.toy_id;
+ALTER TABLE loans ALTER COLUMN unit_id SET NOT NULL;
+
+DROP INDEX IF EXISTS loans_open_toy_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS loans_open_unit_idx ON loans (unit_id) WHERE returned_at IS NULL;
+
+INSERT INTO permissions (code)
+VALUES
+('units:read'),
+('units:write');
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'toys:inspect';
+DROP TABLE IF EXISTS damage_reports;
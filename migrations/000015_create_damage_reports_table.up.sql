@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS damage_reports (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    loan_id bigint UNIQUE NOT NULL REFERENCES loans ON DELETE CASCADE,
+    toy_id bigint NOT NULL REFERENCES toys ON DELETE CASCADE,
+    unit_id bigint NOT NULL REFERENCES toy_units ON DELETE CASCADE,
+    inspector_id bigint REFERENCES users ON DELETE SET NULL,
+    condition text NOT NULL,
+    missing_parts text[] NOT NULL DEFAULT '{}',
+    photos text[] NOT NULL DEFAULT '{}',
+    notes text NOT NULL DEFAULT '',
+    fee bigint NOT NULL DEFAULT 0,
+    ledger_entry_id bigint REFERENCES ledger_entries ON DELETE SET NULL
+);
+
+ALTER TABLE damage_reports ADD CONSTRAINT damage_reports_condition_check CHECK (condition IN ('new', 'like_new', 'good', 'fair', 'poor'));
+ALTER TABLE damage_reports ADD CONSTRAINT damage_reports_fee_check CHECK (fee >= 0);
+
+CREATE INDEX IF NOT EXISTS damage_reports_toy_idx ON damage_reports (toy_id, created_at);
+
+INSERT INTO permissions (code)
+VALUES
+('toys:inspect');
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code IN ('deliveries:manage', 'deliveries:courier');
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS delivery_slots;
+DROP TABLE IF EXISTS addresses;
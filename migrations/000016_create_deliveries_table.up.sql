@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS addresses (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    label text NOT NULL DEFAULT '',
+    line1 text NOT NULL,
+    line2 text NOT NULL DEFAULT '',
+    city text NOT NULL,
+    postal_code text NOT NULL DEFAULT '',
+    phone text NOT NULL,
+    notes text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS addresses_user_idx ON addresses (user_id);
+
+CREATE TABLE IF NOT EXISTS delivery_slots (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    capacity integer NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE delivery_slots ADD CONSTRAINT delivery_slots_period_check CHECK (ends_at > starts_at);
+ALTER TABLE delivery_slots ADD CONSTRAINT delivery_slots_capacity_check CHECK (capacity >= 0);
+
+CREATE INDEX IF NOT EXISTS delivery_slots_starts_at_idx ON delivery_slots (starts_at);
+
+CREATE TABLE IF NOT EXISTS deliveries (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    kind text NOT NULL,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    address_id bigint NOT NULL REFERENCES addresses ON DELETE RESTRICT,
+    slot_id bigint NOT NULL REFERENCES delivery_slots ON DELETE RESTRICT,
+    checkout_id bigint REFERENCES checkout_requests ON DELETE CASCADE,
+    loan_id bigint REFERENCES loans ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'scheduled',
+    courier_id bigint REFERENCES users ON DELETE SET NULL,
+    note text NOT NULL DEFAULT '',
+    completed_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_kind_check CHECK (
+    (kind = 'delivery' AND checkout_id IS NOT NULL AND loan_id IS NULL) OR
+    (kind = 'pickup' AND loan_id IS NOT NULL AND checkout_id IS NULL)
+);
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_status_check CHECK (status IN ('scheduled', 'delivered', 'failed', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS deliveries_slot_idx ON deliveries (slot_id);
+CREATE INDEX IF NOT EXISTS deliveries_user_idx ON deliveries (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS deliveries_scheduled_checkout_idx ON deliveries (checkout_id) WHERE status = 'scheduled';
+CREATE UNIQUE INDEX IF NOT EXISTS deliveries_scheduled_loan_idx ON deliveries (loan_id) WHERE status = 'scheduled';
+
+INSERT INTO permissions (code)
+VALUES
+('deliveries:manage'),
+('deliveries:courier');
@@ -0,0 +1,20 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS recommended_age text;
+
+UPDATE toys SET recommended_age = CASE
+    WHEN min_age_months % 12 = 0 AND (max_age_months IS NULL OR (max_age_months + 1) % 12 = 0) THEN
+        CASE
+            WHEN max_age_months IS NULL THEN (min_age_months / 12) || '+'
+            WHEN (max_age_months + 1) / 12 - 1 = min_age_months / 12 THEN (min_age_months / 12) || ' years'
+            ELSE (min_age_months / 12) || '-' || ((max_age_months + 1) / 12 - 1) || ' years'
+        END
+    ELSE
+        CASE
+            WHEN max_age_months IS NULL THEN min_age_months || '+ months'
+            WHEN max_age_months = min_age_months THEN min_age_months || ' months'
+            ELSE min_age_months || '-' || max_age_months || ' months'
+        END
+END;
+
+DROP INDEX IF EXISTS toys_age_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS max_age_months;
+ALTER TABLE toys DROP COLUMN IF EXISTS min_age_months;
@@ -0,0 +1,31 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS min_age_months integer NOT NULL DEFAULT 0;
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS max_age_months integer;
+
+-- Existing ages look like "3+", "2-4", "2-4 years" or "6-18 months". They are
+-- in years unless months are mentioned; text without a number keeps the
+-- default range of all ages. The maximum is inclusive, so "2-4 years" ends at
+-- the last month of the fifth year.
+UPDATE toys
+SET min_age_months = parsed.match[1]::integer * parsed.unit,
+    max_age_months = CASE
+        WHEN parsed.match[3] IS NOT NULL THEN (parsed.match[3]::integer + 1) * parsed.unit - 1
+        WHEN parsed.match[2] IS NULL THEN (parsed.match[1]::integer + 1) * parsed.unit - 1
+    END
+FROM (
+    SELECT id,
+        regexp_match(recommended_age, '(\d+)\s*(?:(\+)|-\s*(\d+))?') AS match,
+        CASE WHEN recommended_age ~* '(month|мес)' THEN 1 ELSE 12 END AS unit
+    FROM toys
+) AS parsed
+WHERE parsed.id = toys.id AND parsed.match IS NOT NULL;
+
+-- A range that ends in the first month, such as "0 months", can't be told
+-- apart from an unbounded one in the API, so it is stored as unbounded too.
+UPDATE toys SET max_age_months = NULL WHERE max_age_months < min_age_months OR max_age_months = 0;
+
+ALTER TABLE toys ADD CONSTRAINT toys_min_age_months_check CHECK (min_age_months >= 0);
+ALTER TABLE toys ADD CONSTRAINT toys_max_age_months_check CHECK (max_age_months >= min_age_months);
+
+CREATE INDEX IF NOT EXISTS toys_age_idx ON toys (min_age_months, max_age_months);
+
+ALTER TABLE toys DROP COLUMN IF EXISTS recommended_age;
@@ -0,0 +1,2 @@
+ALTER TABLE loans DROP COLUMN IF EXISTS child_id;
+DROP TABLE IF EXISTS children;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS children (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    birth_date date NOT NULL,
+    interests text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE children ADD CONSTRAINT children_interests_length_check CHECK (array_length(interests, 1) <= 10);
+
+CREATE INDEX IF NOT EXISTS children_user_idx ON children (user_id);
+
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS child_id bigint REFERENCES children ON DELETE SET NULL;
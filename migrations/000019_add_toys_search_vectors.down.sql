@@ -0,0 +1,8 @@
+CREATE INDEX IF NOT EXISTS toys_title_idx ON toys USING GIN (to_tsvector('simple', title));
+DROP INDEX IF EXISTS toys_search_simple_idx;
+DROP INDEX IF EXISTS toys_search_en_idx;
+DROP INDEX IF EXISTS toys_search_ru_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS search_simple;
+ALTER TABLE toys DROP COLUMN IF EXISTS search_en;
+ALTER TABLE toys DROP COLUMN IF EXISTS search_ru;
+DROP FUNCTION IF EXISTS toys_array_text(text[]);
@@ -0,0 +1,34 @@
+-- array_to_string is only stable, which generated columns don't accept. It is
+-- immutable for text arrays, so it is wrapped to be usable in them.
+CREATE OR REPLACE FUNCTION toys_array_text(text[]) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT array_to_string($1, ' ') $$;
+
+-- Titles weigh most, then manufacturers, details and descriptions. There is
+-- no Kazakh stemmer in Postgres, so Kazakh uses the simple configuration.
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS search_ru tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('russian', COALESCE(manufacturer, '')), 'B') ||
+    setweight(to_tsvector('russian', toys_array_text(COALESCE(details, '{}'))), 'C') ||
+    setweight(to_tsvector('russian', COALESCE(description, '')), 'D')
+) STORED;
+
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS search_en tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(manufacturer, '')), 'B') ||
+    setweight(to_tsvector('english', toys_array_text(COALESCE(details, '{}'))), 'C') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'D')
+) STORED;
+
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS search_simple tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(manufacturer, '')), 'B') ||
+    setweight(to_tsvector('simple', toys_array_text(COALESCE(details, '{}'))), 'C') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'D')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS toys_search_ru_idx ON toys USING GIN (search_ru);
+CREATE INDEX IF NOT EXISTS toys_search_en_idx ON toys USING GIN (search_en);
+CREATE INDEX IF NOT EXISTS toys_search_simple_idx ON toys USING GIN (search_simple);
+
+DROP INDEX IF EXISTS toys_title_idx;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS toys_manufacturer_trgm_idx;
+DROP INDEX IF EXISTS toys_title_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,4 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS toys_title_trgm_idx ON toys USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS toys_manufacturer_trgm_idx ON toys USING GIN (manufacturer gin_trgm_ops);
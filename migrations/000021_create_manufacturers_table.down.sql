@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS manufacturers_rename_toys ON manufacturers;
+DROP FUNCTION IF EXISTS manufacturers_rename_toys();
+DROP TRIGGER IF EXISTS toys_copy_manufacturer_name ON toys;
+DROP FUNCTION IF EXISTS toys_copy_manufacturer_name();
+DROP INDEX IF EXISTS toys_manufacturer_id_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS manufacturer_id;
+DROP TABLE IF EXISTS manufacturers;
+DROP FUNCTION IF EXISTS manufacturer_key(text);
@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS manufacturers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    country text NOT NULL DEFAULT '',
+    website text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+-- Names that differ only in case, punctuation, spacing or a company suffix,
+-- such as "LEGO" and "Lego Group", belong to the same manufacturer.
+CREATE OR REPLACE FUNCTION manufacturer_key(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT btrim(regexp_replace(
+        btrim(regexp_replace(lower($1), '[[:punct:][:space:]]+', ' ', 'g')),
+        ' (group|inc|ltd|llc|gmbh|co|corp|corporation|company)$', '')) $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS manufacturers_name_key ON manufacturers (manufacturer_key(name));
+CREATE INDEX IF NOT EXISTS manufacturers_name_trgm_idx ON manufacturers USING GIN (name gin_trgm_ops);
+
+-- Each manufacturer is named after the most common spelling of its toys.
+INSERT INTO manufacturers (name)
+SELECT DISTINCT ON (key) name
+FROM (
+    SELECT manufacturer_key(manufacturer) AS key, btrim(manufacturer) AS name, count(*) AS toys
+    FROM toys
+    WHERE manufacturer_key(COALESCE(manufacturer, '')) <> ''
+    GROUP BY 1, 2
+) AS spellings
+ORDER BY key, toys DESC, name
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS manufacturer_id bigint REFERENCES manufacturers ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS toys_manufacturer_id_idx ON toys (manufacturer_id);
+
+UPDATE toys
+SET manufacturer_id = manufacturers.id, manufacturer = manufacturers.name
+FROM manufacturers
+WHERE manufacturer_key(toys.manufacturer) = manufacturer_key(manufacturers.name);
+
+-- toys.manufacturer stays as a copy of the manufacturer's name, which the
+-- search vectors and trigram index of toys are built from.
+CREATE OR REPLACE FUNCTION toys_copy_manufacturer_name() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.manufacturer := (SELECT name FROM manufacturers WHERE id = NEW.manufacturer_id);
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER toys_copy_manufacturer_name
+    BEFORE INSERT OR UPDATE OF manufacturer_id ON toys
+    FOR EACH ROW EXECUTE FUNCTION toys_copy_manufacturer_name();
+
+CREATE OR REPLACE FUNCTION manufacturers_rename_toys() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    UPDATE toys SET manufacturer = NEW.name WHERE manufacturer_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER manufacturers_rename_toys
+    AFTER UPDATE OF name ON manufacturers
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION manufacturers_rename_toys();
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code IN ('taxonomy:write');
+DROP FUNCTION IF EXISTS taxonomy_descendants(text, text);
+DROP FUNCTION IF EXISTS taxonomy_slug(text);
+DROP TABLE IF EXISTS taxonomy_terms;
@@ -0,0 +1,71 @@
+CREATE TABLE IF NOT EXISTS taxonomy_terms (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    vocabulary text NOT NULL,
+    slug text NOT NULL,
+    parent_id bigint,
+    labels jsonb NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT taxonomy_terms_vocabulary_slug_key UNIQUE (vocabulary, slug),
+    CONSTRAINT taxonomy_terms_vocabulary_id_key UNIQUE (vocabulary, id),
+    -- A parent is always from the same vocabulary as its children.
+    CONSTRAINT taxonomy_terms_parent_fkey FOREIGN KEY (vocabulary, parent_id)
+        REFERENCES taxonomy_terms (vocabulary, id) ON DELETE RESTRICT
+);
+
+ALTER TABLE taxonomy_terms ADD CONSTRAINT taxonomy_terms_vocabulary_check CHECK (vocabulary IN ('category', 'skill'));
+ALTER TABLE taxonomy_terms ADD CONSTRAINT taxonomy_terms_parent_check CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS taxonomy_terms_parent_id_idx ON taxonomy_terms (parent_id);
+
+CREATE OR REPLACE FUNCTION taxonomy_slug(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT replace(btrim(regexp_replace(lower($1), '[[:punct:][:space:]]+', ' ', 'g')), ' ', '-') $$;
+
+-- taxonomy_descendants is the slug and the slugs of all terms below it. A
+-- slug that is not a term is returned alone.
+CREATE OR REPLACE FUNCTION taxonomy_descendants(text, text) RETURNS text[]
+    LANGUAGE sql STABLE PARALLEL SAFE
+    AS $$
+WITH RECURSIVE tree AS (
+    SELECT id, slug FROM taxonomy_terms WHERE vocabulary = $1 AND slug = $2
+    UNION
+    SELECT taxonomy_terms.id, taxonomy_terms.slug
+    FROM taxonomy_terms
+    INNER JOIN tree ON taxonomy_terms.parent_id = tree.id
+)
+SELECT ARRAY[$2] || ARRAY(SELECT slug FROM tree WHERE slug <> $2)
+$$;
+
+-- The values already used by toys become the first terms, labelled in
+-- Russian with their most common spelling.
+INSERT INTO taxonomy_terms (vocabulary, slug, labels)
+SELECT DISTINCT ON (vocabulary, slug) vocabulary, slug, jsonb_build_object('ru', label)
+FROM (
+    SELECT 'category' AS vocabulary, taxonomy_slug(category) AS slug, btrim(category) AS label, count(*) AS toys
+    FROM toys, unnest(categories) AS category
+    GROUP BY 1, 2, 3
+    UNION ALL
+    SELECT 'skill', taxonomy_slug(skill), btrim(skill), count(*)
+    FROM toys, unnest(skills) AS skill
+    GROUP BY 1, 2, 3
+) AS spellings
+WHERE slug <> ''
+ORDER BY vocabulary, slug, toys DESC, label
+ON CONFLICT DO NOTHING;
+
+UPDATE toys SET
+    categories = ARRAY(SELECT DISTINCT taxonomy_slug(category) FROM unnest(categories) AS category WHERE taxonomy_slug(category) <> ''),
+    skills = ARRAY(SELECT DISTINCT taxonomy_slug(skill) FROM unnest(skills) AS skill WHERE taxonomy_slug(skill) <> '');
+
+-- Interests of children are matched against skills, so the ones naming a
+-- skill are renamed to its slug.
+UPDATE children SET interests = ARRAY(
+    SELECT DISTINCT COALESCE(taxonomy_terms.slug, interest)
+    FROM unnest(interests) AS interest
+    LEFT JOIN taxonomy_terms ON taxonomy_terms.vocabulary = 'skill' AND taxonomy_terms.slug = taxonomy_slug(interest)
+);
+
+INSERT INTO permissions (code)
+VALUES
+('taxonomy:write');
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS toys_default_sku ON toys;
+DROP FUNCTION IF EXISTS toys_default_sku();
+ALTER TABLE toys DROP CONSTRAINT IF EXISTS toys_sku_key;
+ALTER TABLE toys DROP COLUMN IF EXISTS sku;
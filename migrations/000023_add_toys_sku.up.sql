@@ -0,0 +1,22 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS sku text;
+
+-- Catalog imports match toys by SKU, so every toy needs one for an export to
+-- import back. Toys saved without a SKU get one made from their id.
+UPDATE toys SET sku = 'toy-' || id WHERE sku IS NULL;
+
+CREATE OR REPLACE FUNCTION toys_default_sku() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.sku := COALESCE(NEW.sku, 'toy-' || NEW.id);
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER toys_default_sku
+    BEFORE INSERT OR UPDATE OF sku ON toys
+    FOR EACH ROW EXECUTE FUNCTION toys_default_sku();
+
+ALTER TABLE toys ALTER COLUMN sku SET NOT NULL;
+
+ALTER TABLE toys ADD CONSTRAINT toys_sku_key UNIQUE (sku);
@@ -0,0 +1,10 @@
+CREATE OR REPLACE FUNCTION manufacturers_rename_toys() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    UPDATE toys SET manufacturer = NEW.name WHERE manufacturer_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+ALTER TABLE toys DROP COLUMN IF EXISTS version;
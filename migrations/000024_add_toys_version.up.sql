@@ -0,0 +1,11 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+
+-- Renaming a manufacturer changes its toys, so it counts as an edit of them.
+CREATE OR REPLACE FUNCTION manufacturers_rename_toys() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    UPDATE toys SET manufacturer = NEW.name, version = version + 1 WHERE manufacturer_id = NEW.id;
+    RETURN NULL;
+END
+$$;
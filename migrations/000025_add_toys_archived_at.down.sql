@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('toys:admin');
+DROP INDEX IF EXISTS toys_archived_at_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS archived_at;
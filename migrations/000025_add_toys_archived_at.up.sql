@@ -0,0 +1,7 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS archived_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS toys_archived_at_idx ON toys (archived_at) WHERE archived_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES
+('toys:admin');
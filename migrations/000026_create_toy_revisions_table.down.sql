@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS toys_record_revision ON toys;
+DROP FUNCTION IF EXISTS toys_record_revision();
+DROP FUNCTION IF EXISTS toy_revision_fields(toys);
+DROP TABLE IF EXISTS toy_revisions;
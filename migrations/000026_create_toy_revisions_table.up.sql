@@ -0,0 +1,91 @@
+-- toy_revisions is the change history of toys. It isn't tied to toys by a
+-- foreign key, so that the history of a purged toy outlives it.
+CREATE TABLE IF NOT EXISTS toy_revisions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    toy_id bigint NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL CHECK (action IN ('insert', 'update', 'delete')),
+    changes jsonb NOT NULL,
+    snapshot jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS toy_revisions_toy_id_idx ON toy_revisions (toy_id, id);
+
+-- toy_revision_fields returns the fields of a toy whose changes are recorded.
+CREATE OR REPLACE FUNCTION toy_revision_fields(toys) RETURNS jsonb
+    LANGUAGE sql IMMUTABLE
+    AS $$
+SELECT jsonb_build_object(
+    'sku', $1.sku,
+    'title', $1.title,
+    'description', $1.description,
+    'details', $1.details,
+    'skills', $1.skills,
+    'categories', $1.categories,
+    'images', $1.images,
+    'min_age_months', $1.min_age_months,
+    'max_age_months', $1.max_age_months,
+    'manufacturer_id', $1.manufacturer_id,
+    'manufacturer', $1.manufacturer,
+    'value', $1.value,
+    'archived_at', $1.archived_at
+)
+$$;
+
+-- Every change of a toy is recorded with the fields that changed and a
+-- snapshot of the toy after it, or before it for a delete. The acting user is
+-- read from the oynas.user_id setting of the transaction, and changes made
+-- without one are recorded as made by the system.
+CREATE OR REPLACE FUNCTION toys_record_revision() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    old_fields jsonb := '{}';
+    new_fields jsonb := '{}';
+    changes jsonb;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_fields := toy_revision_fields(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_fields := toy_revision_fields(NEW);
+    END IF;
+
+    SELECT COALESCE(jsonb_object_agg(key, jsonb_build_object(
+        'before', COALESCE(old_fields -> key, 'null'),
+        'after', COALESCE(new_fields -> key, 'null')
+    )), '{}')
+    INTO changes
+    FROM jsonb_object_keys(old_fields || new_fields) AS key
+    WHERE COALESCE(old_fields -> key, 'null') IS DISTINCT FROM COALESCE(new_fields -> key, 'null');
+
+    IF changes = '{}' THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO toy_revisions (toy_id, user_id, action, changes, snapshot)
+    VALUES (
+        CASE WHEN TG_OP = 'DELETE' THEN OLD.id ELSE NEW.id END,
+        NULLIF(current_setting('oynas.user_id', true), '')::bigint,
+        lower(TG_OP),
+        changes,
+        CASE WHEN TG_OP = 'DELETE' THEN old_fields ELSE new_fields END
+    );
+
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER toys_record_revision
+    AFTER INSERT OR UPDATE OR DELETE ON toys
+    FOR EACH ROW EXECUTE FUNCTION toys_record_revision();
+
+-- Existing toys start their history with their current state.
+INSERT INTO toy_revisions (created_at, toy_id, action, changes, snapshot)
+SELECT toys.created_at, toys.id, 'insert', changes.changes, toy_revision_fields(toys)
+FROM toys, LATERAL (
+    SELECT jsonb_object_agg(key, jsonb_build_object('before', 'null'::jsonb, 'after', value)) AS changes
+    FROM jsonb_each(jsonb_strip_nulls(toy_revision_fields(toys)))
+) AS changes
+ORDER BY toys.id;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS bundles (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    title text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    images text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS bundle_toys (
+    bundle_id bigint NOT NULL REFERENCES bundles ON DELETE CASCADE,
+    toy_id bigint NOT NULL REFERENCES toys ON DELETE CASCADE,
+    position integer NOT NULL,
+    PRIMARY KEY (bundle_id, toy_id)
+);
+
+CREATE INDEX IF NOT EXISTS bundle_toys_toy_id_idx ON bundle_toys (toy_id);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS loans_count_toy_loans ON loans;
+DROP FUNCTION IF EXISTS loans_count_toy_loans();
+DROP TRIGGER IF EXISTS comments_refresh_toy_rating ON comments;
+DROP FUNCTION IF EXISTS comments_refresh_toy_rating();
+DROP FUNCTION IF EXISTS toys_refresh_rating(bigint);
+DROP INDEX IF EXISTS toys_loan_count_idx;
+DROP INDEX IF EXISTS toys_rating_average_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS loan_count;
+ALTER TABLE toys DROP COLUMN IF EXISTS rating_histogram;
+ALTER TABLE toys DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE toys DROP COLUMN IF EXISTS rating_average;
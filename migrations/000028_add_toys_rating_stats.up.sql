@@ -0,0 +1,73 @@
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS rating_average numeric(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS rating_histogram integer[] NOT NULL DEFAULT '{0,0,0,0,0}';
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS loan_count integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS toys_rating_average_idx ON toys (rating_average, rating_count);
+CREATE INDEX IF NOT EXISTS toys_loan_count_idx ON toys (loan_count);
+
+-- toys_refresh_rating recounts the rating stats of a toy from its comments.
+-- Comments rated outside 1 to 5 stars, or not at all, are left out.
+CREATE OR REPLACE FUNCTION toys_refresh_rating(bigint) RETURNS void
+    LANGUAGE sql
+    AS $$
+UPDATE toys
+SET rating_average = stats.average, rating_count = stats.count, rating_histogram = stats.histogram
+FROM (
+    SELECT COALESCE(round(avg(rating), 2), 0) AS average, count(*) AS count,
+        ARRAY(
+            SELECT count(comments.id)::integer
+            FROM generate_series(1, 5) AS star
+            LEFT JOIN comments ON comments.toy_id = $1 AND comments.rating = star
+            GROUP BY star
+            ORDER BY star
+        ) AS histogram
+    FROM comments
+    WHERE toy_id = $1 AND rating BETWEEN 1 AND 5
+) AS stats
+WHERE toys.id = $1
+$$;
+
+CREATE OR REPLACE FUNCTION comments_refresh_toy_rating() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM toys_refresh_rating(NEW.toy_id);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM toys_refresh_rating(OLD.toy_id);
+    ELSE
+        PERFORM toys_refresh_rating(OLD.toy_id);
+        IF NEW.toy_id IS DISTINCT FROM OLD.toy_id THEN
+            PERFORM toys_refresh_rating(NEW.toy_id);
+        END IF;
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER comments_refresh_toy_rating
+    AFTER INSERT OR UPDATE OF toy_id, rating OR DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_refresh_toy_rating();
+
+-- A toy's popularity is the number of times it has been lent out.
+CREATE OR REPLACE FUNCTION loans_count_toy_loans() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE toys SET loan_count = loan_count + 1 WHERE id = NEW.toy_id;
+    ELSE
+        UPDATE toys SET loan_count = loan_count - 1 WHERE id = OLD.toy_id;
+    END IF;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER loans_count_toy_loans
+    AFTER INSERT OR DELETE ON loans
+    FOR EACH ROW EXECUTE FUNCTION loans_count_toy_loans();
+
+SELECT toys_refresh_rating(id) FROM toys;
+
+UPDATE toys SET loan_count = (SELECT count(*) FROM loans WHERE loans.toy_id = toys.id);
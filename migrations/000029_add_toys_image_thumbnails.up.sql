@@ -0,0 +1,2 @@
+-- The thumbnails of uploaded images, as {"<image url>": {"<size>": "<url>"}}.
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS image_thumbnails jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1,14 @@
+-- plan_changes records every subscription change under the idempotency key
+-- it was made with, so that a retry returns the same result even when the
+-- change was free and posted nothing to the ledger.
+CREATE TABLE IF NOT EXISTS plan_changes (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    plan_id bigint NOT NULL REFERENCES plans ON DELETE CASCADE,
+    change text NOT NULL CHECK (change IN ('subscribed', 'upgraded', 'downgraded')),
+    paid_until timestamp(0) with time zone,
+    ledger_entry_id bigint REFERENCES ledger_entries ON DELETE SET NULL,
+    idempotency_key text NOT NULL,
+    UNIQUE (user_id, idempotency_key)
+);
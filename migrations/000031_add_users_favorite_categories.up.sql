@@ -0,0 +1,3 @@
+-- favorite_categories are the taxonomy category slugs a user picked
+-- themselves. Recommendations weigh them separately from rental history.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS favorite_categories text[] NOT NULL DEFAULT '{}';
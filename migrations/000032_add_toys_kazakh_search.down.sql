@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS toys_search_kk_idx;
+ALTER TABLE toys DROP COLUMN IF EXISTS search_kk;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS kazakh;
+DROP TEXT SEARCH DICTIONARY IF EXISTS kazakh_hunspell;
@@ -0,0 +1,26 @@
+-- Postgres ships no Kazakh stemmer, so Kazakh is stemmed with the kk_KZ
+-- hunspell dictionary. Its files have to be in the server's tsearch_data
+-- directory as kk_kz.dict and kk_kz.affix, in UTF-8, before this migration
+-- runs (on Debian: install hunspell-kk and copy /usr/share/hunspell/kk_KZ.dic
+-- and kk_KZ.aff there under those names). Words the dictionary doesn't know
+-- fall through to the simple dictionary and are kept as they are.
+CREATE TEXT SEARCH DICTIONARY kazakh_hunspell (
+    TEMPLATE = ispell,
+    DictFile = kk_kz,
+    AffFile = kk_kz
+);
+
+CREATE TEXT SEARCH CONFIGURATION kazakh (COPY = simple);
+
+ALTER TEXT SEARCH CONFIGURATION kazakh
+    ALTER MAPPING FOR asciiword, asciihword, hword_asciipart, word, hword, hword_part
+    WITH kazakh_hunspell, simple;
+
+ALTER TABLE toys ADD COLUMN IF NOT EXISTS search_kk tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('kazakh', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('kazakh', COALESCE(manufacturer, '')), 'B') ||
+    setweight(to_tsvector('kazakh', toys_array_text(COALESCE(details, '{}'))), 'C') ||
+    setweight(to_tsvector('kazakh', COALESCE(description, '')), 'D')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS toys_search_kk_idx ON toys USING GIN (search_kk);